@@ -9,17 +9,31 @@ package amqp
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"framework/pkg/telemetry"
+	"framework/pkg/trace"
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill-amqp/v2/pkg/amqp"
 	"github.com/ThreeDotsLabs/watermill/message"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"strconv"
+	"sync"
+	"time"
 )
 
+const publishedAtKey = "publishedAt" // 发布时间元数据，毫秒时间戳
+
 // Amqp 消息队列
 type Amqp[P any, T any] struct {
-	topic      string                  // 主题
-	amqpConfig amqp.Config             // 配置
-	logAdapter watermill.LoggerAdapter // 适配器
-	publisher  *amqp.Publisher         // 发布者
+	topic       string                  // 主题
+	amqpConfig  amqp.Config             // 配置
+	logAdapter  watermill.LoggerAdapter // 适配器
+	publisher   *amqp.Publisher         // 发布者
+	subscribers []*amqp.Subscriber      // 订阅者
+	mutex       sync.Mutex              // 订阅者锁
+	waitGroup   sync.WaitGroup          // 处理中的消息
+	telemetry   *telemetry.Telemetry    // 遥测
 }
 
 // New 创建
@@ -39,13 +53,41 @@ func New[P any, T any](config Config, topic string) (mq *Amqp[P, T], err error)
 }
 
 // Publish 发布
-func (mq Amqp[P, T]) Publish(msgId string, t T) error {
+func (mq *Amqp[P, T]) Publish(msgId string, t T) error {
+	return mq.PublishContext(context.Background(), msgId, t)
+}
+
+// PublishContext 发布，写入发布 span 或上下文请求链路到消息元数据
+func (mq *Amqp[P, T]) PublishContext(ctx context.Context, msgId string, t T) error {
+	ctx, span := mq.telemetry.StartSpan(ctx, mq.topic+" publish", oteltrace.SpanKindProducer, mq.attributes(msgId)...)
+
+	err := mq.publisher.Publish(mq.topic, newMessage(ctx, span, msgId, t))
+	telemetry.EndSpan(span, err)
+
+	return err
+}
+
+// 创建消息，发布 span 有效时总是写入其 traceparent，以便消费 span 以发布 span 为父
+func newMessage[T any](ctx context.Context, span oteltrace.Span, msgId string, t T) *message.Message {
 	tBytes, _ := json.Marshal(t)
-	return mq.publisher.Publish(mq.topic, message.NewMessage(msgId, tBytes))
+	msg := message.NewMessage(msgId, tBytes)
+	msg.Metadata.Set(publishedAtKey, strconv.FormatInt(time.Now().UnixMilli(), 10))
+
+	if requestTrace, ok := trace.FromContext(ctx); ok {
+		msg.Metadata.Set(trace.RequestIdHeader, requestTrace.RequestId)
+		msg.Metadata.Set(trace.TraceparentHeader, requestTrace.Traceparent())
+	}
+
+	if spanContext := span.SpanContext(); spanContext.IsValid() {
+		msg.Metadata.Set(trace.TraceparentHeader, "00-"+spanContext.TraceID().String()+"-"+
+			spanContext.SpanID().String()+"-"+spanContext.TraceFlags().String())
+	}
+
+	return msg
 }
 
 // Subscriber 订阅
-func (mq Amqp[P, T]) Subscriber(this P, subscriber Subscriber[P, T]) error {
+func (mq *Amqp[P, T]) Subscriber(this P, subscriber Subscriber[P, T]) error {
 	var amqpSubscriber *amqp.Subscriber
 	var err error
 
@@ -53,13 +95,20 @@ func (mq Amqp[P, T]) Subscriber(this P, subscriber Subscriber[P, T]) error {
 		return err
 	}
 
+	mq.mutex.Lock()
+	mq.subscribers = append(mq.subscribers, amqpSubscriber)
+	mq.mutex.Unlock()
+
 	var messages <-chan *message.Message
 	messages, err = amqpSubscriber.Subscribe(context.Background(), mq.topic)
 	if err != nil {
 		return err
 	}
 
+	mq.waitGroup.Add(1)
 	go func(messages <-chan *message.Message) {
+		defer mq.waitGroup.Done()
+
 		for msg := range messages {
 			var t T
 			if err = json.Unmarshal(msg.Payload, &t); err != nil {
@@ -68,7 +117,7 @@ func (mq Amqp[P, T]) Subscriber(this P, subscriber Subscriber[P, T]) error {
 				}
 			}
 
-			if subscriber.OnProcess(this, msg.UUID, t) {
+			if mq.process(this, subscriber, msg, t) {
 				msg.Ack()
 			}
 		}
@@ -77,7 +126,55 @@ func (mq Amqp[P, T]) Subscriber(this P, subscriber Subscriber[P, T]) error {
 	return nil
 }
 
-// Stop 停止
-func (mq Amqp[P, T]) Stop() error {
-	return mq.publisher.Close()
+// 处理消息，上下文恢复发布方请求链路，无链路时生成
+func (mq *Amqp[P, T]) process(this P, subscriber Subscriber[P, T], msg *message.Message, t T) (ack bool) {
+	if publishedAt, err := strconv.ParseInt(msg.Metadata.Get(publishedAtKey), 10, 64); err == nil {
+		mq.telemetry.ObserveConsumerLag(mq.topic, time.Since(time.UnixMilli(publishedAt)))
+	}
+
+	ctx := trace.NewContext(msg.Context(),
+		trace.Parse(msg.Metadata.Get(trace.RequestIdHeader), msg.Metadata.Get(trace.TraceparentHeader)))
+
+	ctx, span := mq.telemetry.StartSpan(ctx, mq.topic+" process", oteltrace.SpanKindConsumer, mq.attributes(msg.UUID)...)
+	defer func() {
+		span.SetAttributes(attribute.Bool("messaging.ack", ack))
+		span.End()
+	}()
+
+	if contextSubscriber, ok := subscriber.(ContextSubscriber[P, T]); ok {
+		return contextSubscriber.OnProcessContext(ctx, this, msg.UUID, t)
+	}
+
+	return subscriber.OnProcess(this, msg.UUID, t)
+}
+
+// span 属性
+func (mq *Amqp[P, T]) attributes(msgId string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("messaging.system", "rabbitmq"),
+		attribute.String("messaging.destination.name", mq.topic),
+		attribute.String("messaging.message.id", msgId),
+	}
+}
+
+// UseTelemetry 启用遥测，记录发布与消费 span 及消费延迟
+func (mq *Amqp[P, T]) UseTelemetry(t *telemetry.Telemetry) {
+	mq.telemetry = t
+}
+
+// Stop 停止，先关闭订阅者并等待处理中的消息完成，再关闭发布者
+func (mq *Amqp[P, T]) Stop() error {
+	var errs []error
+
+	mq.mutex.Lock()
+	for _, amqpSubscriber := range mq.subscribers {
+		errs = append(errs, amqpSubscriber.Close())
+	}
+	mq.subscribers = nil
+	mq.mutex.Unlock()
+
+	mq.waitGroup.Wait()
+
+	errs = append(errs, mq.publisher.Close())
+	return errors.Join(errs...)
 }
@@ -7,10 +7,20 @@
 package amqp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"framework/pkg/telemetry"
+	"framework/pkg/trace"
+	"strconv"
 	"testing"
 	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/prometheus/client_golang/prometheus"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	oteltrace "go.opentelemetry.io/otel/trace"
 )
 
 type SmsSend struct {
@@ -59,3 +69,97 @@ func TestMq_Publish(t *testing.T) {
 	time.Sleep(10 * time.Second)
 	_ = mq.Stop()
 }
+
+type SmsSendContextSubscriber struct {
+	SmsSendSubscriber
+	trace trace.Trace
+}
+
+func (smsSendContextSubscriber *SmsSendContextSubscriber) OnProcessContext(ctx context.Context, _ any, _ string, _ SmsSend) (ack bool) {
+	smsSendContextSubscriber.trace, _ = trace.FromContext(ctx)
+	return true
+}
+
+func TestMq_Process(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	registry := prometheus.NewRegistry()
+
+	mq := &Amqp[any, SmsSend]{
+		topic: "sms.send.topic",
+	}
+	mq.UseTelemetry(telemetry.New(telemetry.Config{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		Registry:       registry,
+	}))
+
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	msg := message.NewMessage("1", nil)
+	msg.Metadata.Set(trace.RequestIdHeader, "upstream-1")
+	msg.Metadata.Set(trace.TraceparentHeader, "00-"+traceId+"-00f067aa0ba902b7-01")
+	msg.Metadata.Set(publishedAtKey, strconv.FormatInt(time.Now().Add(-2*time.Second).UnixMilli(), 10))
+
+	subscriber := &SmsSendContextSubscriber{}
+	if !mq.process(nil, subscriber, msg, SmsSend{}) {
+		t.Fatalf("not ack")
+	}
+
+	// 消费 span 以发布方为父
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "sms.send.topic process" || spans[0].Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("spans %v", spans)
+	}
+
+	if subscriber.trace.RequestId != "upstream-1" || subscriber.trace.TraceId != traceId ||
+		subscriber.trace.SpanId != spans[0].SpanContext.SpanID().String() {
+		t.Fatalf("trace %+v", subscriber.trace)
+	}
+
+	// 消费延迟
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, family := range families {
+		if family.GetName() == "amqp_consumer_lag_seconds" {
+			histogram := family.GetMetric()[0].GetHistogram()
+			if histogram.GetSampleCount() != 1 || histogram.GetSampleSum() < 2 {
+				t.Fatalf("lag count %d, sum %f", histogram.GetSampleCount(), histogram.GetSampleSum())
+			}
+			return
+		}
+	}
+
+	t.Fatalf("lag metric missing")
+}
+
+func TestMq_PublishTrace(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	mq := &Amqp[any, SmsSend]{
+		topic: "sms.send.topic",
+	}
+	mq.UseTelemetry(telemetry.New(telemetry.Config{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+	}))
+
+	// 无请求链路时同样写入发布 span
+	ctx, span := mq.telemetry.StartSpan(context.Background(), mq.topic+" publish", oteltrace.SpanKindProducer)
+	msg := newMessage(ctx, span, "1", SmsSend{})
+	span.End()
+
+	if msg.Metadata.Get(trace.TraceparentHeader) == "" {
+		t.Fatalf("traceparent missing")
+	}
+
+	if !mq.process(nil, &SmsSendContextSubscriber{}, msg, SmsSend{}) {
+		t.Fatalf("not ack")
+	}
+
+	// 消费 span 以发布 span 为父
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[1].Parent.SpanID() != spans[0].SpanContext.SpanID() ||
+		spans[1].SpanContext.TraceID() != spans[0].SpanContext.TraceID() {
+		t.Fatalf("spans %v", spans)
+	}
+}
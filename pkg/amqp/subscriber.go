@@ -6,8 +6,15 @@
 
 package amqp
 
+import "context"
+
 // Subscriber 订阅接口
 type Subscriber[P any, T any] interface {
 	OnProcess(this P, msgId string, t T) (ack bool)     // 成功
 	OnError(this P, msgId string, err error) (ack bool) // 失败
 }
+
+// ContextSubscriber 上下文订阅接口，实现时替代 Subscriber.OnProcess，上下文含发布方请求链路
+type ContextSubscriber[P any, T any] interface {
+	OnProcessContext(ctx context.Context, this P, msgId string, t T) (ack bool) // 成功
+}
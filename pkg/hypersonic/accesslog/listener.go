@@ -0,0 +1,292 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-06 11:12:09
+ */
+
+package accesslog
+
+import (
+	"context"
+	"framework/pkg/hypersonic"
+	"framework/pkg/ip2region"
+	"framework/pkg/mysql"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config 配置
+type Config struct {
+	QueueSize     int                               // 队列长度，默认 10000
+	BatchSize     int                               // 批量写入条数，默认 200
+	FlushInterval time.Duration                     // 定时写入间隔，默认 1 秒
+	Timeout       time.Duration                     // 队列满时请求等待入队的时间，默认 100 毫秒，超时丢弃，小于 0 时直接丢弃
+	MaxBodySize   int                               // 请求体与响应保存字节数，默认 4096，小于 0 时不保存
+	RedactFields  []string                          // 请求体与响应脱敏字段，默认 password，规则同 hypersonic.LogConfig
+	Locate        func(ip string) ip2region.Address // ip 定位，默认 ip2region.SearchIpStr
+}
+
+// Listener 访问日志监听者，异步批量写入 mysql 按月分表，服务停止时以 Close 写入剩余日志
+type Listener struct {
+	hypersonic.NopListener
+
+	config  Config         // 配置
+	store   store          // 存储
+	queue   chan AccessLog // 队列
+	dropped atomic.Int64   // 丢弃条数
+	closed  chan struct{}  // 关闭信号
+	done    chan struct{}  // 已写完
+	once    sync.Once      // 关闭一次
+}
+
+// New 创建访问日志监听者，如 h.OnStop("accesslog", listener.Close)
+func New(mysql *mysql.Mysql, config Config) *Listener {
+	return newListener(newRepository(mysql), config)
+}
+
+// 创建访问日志监听者
+func newListener(store store, config Config) *Listener {
+	if config.QueueSize == 0 {
+		config.QueueSize = 10000
+	}
+
+	if config.BatchSize == 0 {
+		config.BatchSize = 200
+	}
+
+	if config.FlushInterval == 0 {
+		config.FlushInterval = time.Second
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = 100 * time.Millisecond
+	}
+
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = 4096
+	}
+
+	if config.RedactFields == nil {
+		config.RedactFields = []string{"password"}
+	}
+
+	if config.Locate == nil {
+		config.Locate = ip2region.SearchIpStr
+	}
+
+	listener := &Listener{
+		config: config,
+		store:  store,
+		queue:  make(chan AccessLog, config.QueueSize),
+		closed: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go listener.run()
+
+	return listener
+}
+
+// 定位 ip，失败时为空
+func (listener *Listener) locate(ip string) (address ip2region.Address) {
+	defer func() {
+		_ = recover()
+	}()
+
+	return listener.config.Locate(ip)
+}
+
+// 写入
+func (listener *Listener) flush(accessLogs []AccessLog) {
+	if len(accessLogs) == 0 {
+		return
+	}
+
+	for i := range accessLogs {
+		accessLogs[i].Address = listener.locate(accessLogs[i].Ip)
+	}
+
+	if err := listener.store.save(accessLogs); err != nil {
+		slog.Error("accesslog save failed", "count", len(accessLogs), "err", err)
+	}
+}
+
+// 批量写入，达到批量条数或定时写入，关闭时写入剩余日志
+func (listener *Listener) run() {
+	defer close(listener.done)
+
+	ticker := time.NewTicker(listener.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AccessLog, 0, listener.config.BatchSize)
+	add := func(accessLog AccessLog) {
+		if batch = append(batch, accessLog); len(batch) >= listener.config.BatchSize {
+			listener.flush(batch)
+			batch = make([]AccessLog, 0, listener.config.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case accessLog := <-listener.queue:
+			add(accessLog)
+		case <-ticker.C:
+			listener.flush(batch)
+			batch = make([]AccessLog, 0, listener.config.BatchSize)
+		case <-listener.closed:
+			for {
+				select {
+				case accessLog := <-listener.queue:
+					add(accessLog)
+				default:
+					listener.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// 入队，队列满时等待 Timeout 后丢弃
+func (listener *Listener) enqueue(accessLog AccessLog) {
+	select {
+	case <-listener.closed:
+		listener.dropped.Add(1)
+		return
+	default:
+	}
+
+	select {
+	case listener.queue <- accessLog:
+		return
+	default:
+	}
+
+	if listener.config.Timeout < 0 {
+		listener.dropped.Add(1)
+		return
+	}
+
+	timer := time.NewTimer(listener.config.Timeout)
+	defer timer.Stop()
+
+	select {
+	case listener.queue <- accessLog:
+	case <-timer.C:
+		listener.dropped.Add(1)
+	case <-listener.closed:
+		listener.dropped.Add(1)
+	}
+}
+
+// 截断，不拆分多字节字符
+func (listener *Listener) truncate(value string) *string {
+	if listener.config.MaxBodySize < 0 {
+		return nil
+	}
+
+	if len(value) > listener.config.MaxBodySize {
+		value = strings.ToValidUTF8(value[:listener.config.MaxBodySize], "")
+	}
+
+	return &value
+}
+
+// OnLog 日志，入队后异步写入
+func (listener *Listener) OnLog(req *hypersonic.Request, data *hypersonic.Data, err *hypersonic.Error) {
+	accessLog := AccessLog{}
+	accessLog.Filed(req, req.Token.GetUserId(), nil, req.GetElapsed())
+	accessLog.Header = nil
+	accessLog.CreatedAt = time.Now()
+
+	contentType := ""
+	if header := req.GetHeader("Content-Type"); header != nil {
+		contentType = *header
+	}
+
+	accessLog.Body = nil
+	if body := req.GetBody(); body != nil {
+		accessLog.Body = listener.truncate(hypersonic.RedactBody(*body, contentType, listener.config.RedactFields))
+	}
+
+	if data != nil {
+		accessLog.Response = listener.truncate(hypersonic.RedactBody(data.String(), "application/json", listener.config.RedactFields))
+	}
+
+	if err != nil {
+		accessLog.Code = string(err.Code)
+		accessLog.Response = listener.truncate(err.String())
+	}
+
+	listener.enqueue(accessLog)
+}
+
+// Dropped 队列满或关闭后丢弃的条数
+func (listener *Listener) Dropped() int64 {
+	return listener.dropped.Load()
+}
+
+// Close 停止接收并写入剩余日志，用于 Hypersonic.OnStop
+func (listener *Listener) Close(ctx context.Context) error {
+	listener.once.Do(func() {
+		close(listener.closed)
+	})
+
+	select {
+	case <-listener.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Find 查询访问日志，按时间倒序，分页默认第 1 页，每页默认 20 条，最多 100 条
+func (listener *Listener) Find(query Query) ([]AccessLog, hypersonic.Pagination) {
+	if query.Page.Page < 1 {
+		query.Page.Page = 1
+	}
+
+	if query.PageSize < 1 {
+		query.PageSize = 20
+	} else if query.PageSize > 100 {
+		query.PageSize = 100
+	}
+
+	if query.End.IsZero() {
+		query.End = time.Now()
+	}
+
+	if query.Begin.IsZero() {
+		query.Begin = query.End.AddDate(0, 0, -30)
+	}
+
+	accessLogs, pagination, err := listener.store.find(query)
+	if err != nil {
+		panic(hypersonic.NewErrorWithMessage(hypersonic.CodeInternalError, err.Error()))
+	}
+
+	return accessLogs, pagination
+}
+
+// Controller 管理接口，GET 按用户、ip、访问地址与时间范围查询访问日志，需具备任一角色
+func (listener *Listener) Controller(path string, roles ...string) hypersonic.Controller {
+	return hypersonic.Controller{
+		Path:  path,
+		Roles: roles,
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "",
+			Handler: hypersonic.Handle(func(req *hypersonic.Request, query *Query) (*hypersonic.Data, *hypersonic.Error) {
+				accessLogs, pagination := listener.Find(*query)
+				return hypersonic.NewData(accessLogs, &pagination), nil
+			}),
+		}},
+	}
+}
+
+// 确保实现监听者接口
+var _ hypersonic.Listener = (*Listener)(nil)
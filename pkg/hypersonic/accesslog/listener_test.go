@@ -0,0 +1,178 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-06 14:02:38
+ */
+
+package accesslog
+
+import (
+	"context"
+	"framework/pkg/hypersonic"
+	"framework/pkg/ip2region"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 内存存储
+type memoryStore struct {
+	mutex   sync.Mutex    // 锁
+	batches [][]AccessLog // 已保存批次
+	block   chan struct{} // 保存前等待
+	query   Query         // 最近查询条件
+}
+
+// 保存
+func (store *memoryStore) save(accessLogs []AccessLog) error {
+	if store.block != nil {
+		<-store.block
+	}
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	store.batches = append(store.batches, append([]AccessLog(nil), accessLogs...))
+	return nil
+}
+
+// 查询
+func (store *memoryStore) find(query Query) ([]AccessLog, hypersonic.Pagination, error) {
+	store.query = query
+	return nil, hypersonic.Pagination{}, nil
+}
+
+// 批次大小
+func (store *memoryStore) sizes() []int {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	sizes := make([]int, 0, len(store.batches))
+	for _, batch := range store.batches {
+		sizes = append(sizes, len(batch))
+	}
+
+	return sizes
+}
+
+// TestListener 测试
+func TestListener(t *testing.T) {
+	t.Run("batch", func(t *testing.T) {
+		store := &memoryStore{}
+		listener := newListener(store, Config{
+			BatchSize:     2,
+			FlushInterval: time.Hour,
+			Locate: func(ip string) ip2region.Address {
+				if ip == "" {
+					panic("empty ip")
+				}
+
+				return ip2region.Address{Country: "中国"}
+			},
+		})
+
+		for _, ip := range []string{"1.1.1.1", "", "2.2.2.2", "3.3.3.3", "4.4.4.4"} {
+			accessLog := AccessLog{}
+			accessLog.Ip = ip
+			listener.enqueue(accessLog)
+		}
+
+		if err := listener.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if sizes := store.sizes(); len(sizes) != 3 || sizes[0] != 2 || sizes[1] != 2 || sizes[2] != 1 {
+			t.Fatalf("unexpected batches %v", sizes)
+		}
+
+		if store.batches[0][0].Country != "中国" || store.batches[0][1].Country != "" {
+			t.Fatalf("unexpected address %+v", store.batches[0])
+		}
+
+		listener.enqueue(AccessLog{})
+		if listener.Dropped() != 1 {
+			t.Fatalf("closed listener should drop, dropped %d", listener.Dropped())
+		}
+	})
+
+	t.Run("interval", func(t *testing.T) {
+		store := &memoryStore{}
+		listener := newListener(store, Config{
+			FlushInterval: 10 * time.Millisecond,
+		})
+		defer func() {
+			_ = listener.Close(context.Background())
+		}()
+
+		listener.enqueue(AccessLog{})
+
+		for i := 0; i < 100 && len(store.sizes()) == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		if sizes := store.sizes(); len(sizes) != 1 || sizes[0] != 1 {
+			t.Fatalf("unexpected batches %v", sizes)
+		}
+	})
+
+	t.Run("backpressure", func(t *testing.T) {
+		store := &memoryStore{
+			block: make(chan struct{}),
+		}
+		listener := newListener(store, Config{
+			QueueSize: 1,
+			BatchSize: 1,
+			Timeout:   20 * time.Millisecond,
+		})
+
+		// 第一条被取出后阻塞在保存，第二条占满队列
+		listener.enqueue(AccessLog{})
+		for len(listener.queue) != 0 {
+			time.Sleep(time.Millisecond)
+		}
+		listener.enqueue(AccessLog{})
+
+		begin := time.Now()
+		listener.enqueue(AccessLog{})
+		if elapsed := time.Since(begin); elapsed < 20*time.Millisecond {
+			t.Fatalf("enqueue should wait timeout, waited %s", elapsed)
+		}
+
+		if listener.Dropped() != 1 {
+			t.Fatalf("unexpected dropped %d", listener.Dropped())
+		}
+
+		close(store.block)
+		if err := listener.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if sizes := store.sizes(); len(sizes) != 2 {
+			t.Fatalf("unexpected batches %v", sizes)
+		}
+	})
+}
+
+func TestListenerFind(t *testing.T) {
+	store := &memoryStore{}
+	listener := newListener(store, Config{})
+	defer func() {
+		_ = listener.Close(context.Background())
+	}()
+
+	// 分页默认值与上限
+	for _, c := range []struct {
+		page     hypersonic.Page
+		expected hypersonic.Page
+	}{
+		{hypersonic.Page{}, hypersonic.Page{Page: 1, PageSize: 20}},
+		{hypersonic.Page{Page: -1, PageSize: 1000}, hypersonic.Page{Page: 1, PageSize: 100}},
+		{hypersonic.Page{Page: 3, PageSize: 10}, hypersonic.Page{Page: 3, PageSize: 10}},
+	} {
+		listener.Find(Query{Page: c.page})
+
+		if store.query.Page != c.expected || store.query.End.IsZero() || !store.query.Begin.Before(store.query.End) {
+			t.Errorf("unexpected query %+v", store.query)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-06 10:04:37
+ */
+
+package accesslog
+
+import (
+	"framework/pkg/hypersonic"
+	"framework/pkg/ip2region"
+	"framework/pkg/mysql"
+	"time"
+)
+
+// AccessLog 访问日志，按月分表，如 tbl_access_log_202408
+type AccessLog struct {
+	mysql.ModelId
+
+	hypersonic.RequestModel `gorm:"embedded"` // 请求
+	ip2region.Address       `gorm:"embedded"` // 位置
+
+	Code string `json:"code,omitempty" gorm:"type:varchar(64); comment:错误码"` // 错误码，成功时为空
+
+	mysql.ModelCreatedAt
+}
+
+// Query 查询条件
+type Query struct {
+	UserId string    `json:"userId" form:"userId"` // 用户 id
+	Ip     string    `json:"ip" form:"ip"`         // ip
+	Uri    string    `json:"uri" form:"uri"`       // 访问地址，包含匹配
+	Begin  time.Time `json:"begin" form:"begin"`   // 开始时间，RFC3339，默认结束时间前 30 天
+	End    time.Time `json:"end" form:"end"`       // 结束时间，RFC3339，默认当前时间
+
+	hypersonic.Page
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-06 10:26:51
+ */
+
+package accesslog
+
+import (
+	"fmt"
+	"framework/pkg/hypersonic"
+	"framework/pkg/mysql"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 存储
+type store interface {
+	save(accessLogs []AccessLog) error                            // 保存
+	find(query Query) ([]AccessLog, hypersonic.Pagination, error) // 查询
+}
+
+// 仓库，按月分表
+type repository struct {
+	mysql  *mysql.Mysql    // mysql
+	mutex  sync.Mutex      // 锁
+	tables map[string]bool // 已存在的分表
+}
+
+// 创建仓库
+func newRepository(mysql *mysql.Mysql) *repository {
+	return &repository{
+		mysql:  mysql,
+		tables: make(map[string]bool),
+	}
+}
+
+// 获取分表名称
+func (repository *repository) tableName(t time.Time) string {
+	return repository.mysql.DB.NamingStrategy.TableName("AccessLog") + "_" + t.Format("200601")
+}
+
+// 分表是否存在
+func (repository *repository) hasTable(name string) bool {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	if !repository.tables[name] && repository.mysql.DB.Migrator().HasTable(name) {
+		repository.tables[name] = true
+	}
+
+	return repository.tables[name]
+}
+
+// 创建分表与索引
+func (repository *repository) createTable(name string) error {
+	repository.mutex.Lock()
+	defer repository.mutex.Unlock()
+
+	db := repository.mysql.DB
+	if repository.tables[name] || db.Migrator().HasTable(name) {
+		repository.tables[name] = true
+		return nil
+	}
+
+	if err := db.Table(name).AutoMigrate(&AccessLog{}); err != nil {
+		return err
+	}
+
+	for index, columns := range map[string]string{
+		"idx_created_at": "created_at",
+		"idx_user_id":    "user_id, created_at",
+		"idx_ip":         "ip, created_at",
+	} {
+		if err := db.Exec(fmt.Sprintf("CREATE INDEX %s ON %s (%s)", index, name, columns)).Error; err != nil {
+			return err
+		}
+	}
+
+	repository.tables[name] = true
+	return nil
+}
+
+// 按月分表批量保存
+func (repository *repository) save(accessLogs []AccessLog) error {
+	groups := make(map[string][]AccessLog)
+	for _, accessLog := range accessLogs {
+		name := repository.tableName(accessLog.CreatedAt)
+		groups[name] = append(groups[name], accessLog)
+	}
+
+	for name, group := range groups {
+		if err := repository.createTable(name); err != nil {
+			return err
+		}
+
+		if err := repository.mysql.DB.Table(name).CreateInBatches(group, len(group)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 转义 like
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// 跨月查询，合并时间范围内的分表并按时间倒序分页
+func (repository *repository) find(query Query) ([]AccessLog, hypersonic.Pagination, error) {
+	accessLogs := make([]AccessLog, 0)
+	pagination := hypersonic.Pagination{
+		Page: query.Page,
+	}
+
+	// 条件
+	where := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("created_at BETWEEN ? AND ?", query.Begin, query.End)
+
+		if query.UserId != "" {
+			db = db.Where("user_id = ?", query.UserId)
+		}
+		if query.Ip != "" {
+			db = db.Where("ip = ?", query.Ip)
+		}
+		if query.Uri != "" {
+			db = db.Where("uri LIKE ?", "%"+escapeLike(query.Uri)+"%")
+		}
+
+		return db
+	}
+
+	// 时间范围内存在的分表
+	subQueries := make([]any, 0)
+	for month := query.Begin.AddDate(0, 0, 1-query.Begin.Day()); !month.After(query.End); month = month.AddDate(0, 1, 0) {
+		if name := repository.tableName(month); repository.hasTable(name) {
+			subQueries = append(subQueries, where(repository.mysql.DB.Table(name)))
+		}
+	}
+
+	if len(subQueries) == 0 {
+		return accessLogs, pagination, nil
+	}
+
+	union := repository.mysql.DB.Raw(strings.Repeat("(?) UNION ALL ", len(subQueries)-1)+"(?)", subQueries...)
+
+	if err := repository.mysql.DB.Table("(?) AS access_log", union).Count(&pagination.TotalSize).Error; err != nil {
+		return nil, pagination, err
+	}
+
+	if err := repository.mysql.DB.Table("(?) AS access_log", union).Order("created_at DESC").
+		Offset((query.Page.Page - 1) * query.PageSize).Limit(query.PageSize).Find(&accessLogs).Error; err != nil {
+		return nil, pagination, err
+	}
+
+	pagination.TotalPage = (pagination.TotalSize + int64(query.PageSize) - 1) / int64(query.PageSize)
+	return accessLogs, pagination, nil
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-15 16:08:22
+ */
+
+package accesslog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"framework/pkg/hypersonic"
+	"framework/pkg/mysql"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+)
+
+// 记录语句的数据库驱动，按语句返回固定结果
+type recordDriver struct {
+	mutex      sync.Mutex      // 锁
+	statements []string        // 已执行语句
+	tables     map[string]bool // 已存在的表
+	count      int64           // count 查询结果
+}
+
+// Open 打开连接
+func (recordDriver *recordDriver) Open(string) (driver.Conn, error) {
+	return &recordConn{driver: recordDriver}, nil
+}
+
+// 记录语句，参数附加在语句后，如 SELECT ... LIMIT ? [10]
+func (recordDriver *recordDriver) record(query string, args []driver.NamedValue) {
+	recordDriver.mutex.Lock()
+	defer recordDriver.mutex.Unlock()
+
+	values := make([]any, 0, len(args))
+	for _, arg := range args {
+		values = append(values, arg.Value)
+	}
+
+	recordDriver.statements = append(recordDriver.statements, fmt.Sprintf("%s %v", query, values))
+}
+
+// 包含子串的语句
+func (recordDriver *recordDriver) find(substr string) []string {
+	recordDriver.mutex.Lock()
+	defer recordDriver.mutex.Unlock()
+
+	statements := make([]string, 0)
+	for _, statement := range recordDriver.statements {
+		if strings.Contains(statement, substr) {
+			statements = append(statements, statement)
+		}
+	}
+
+	return statements
+}
+
+// 连接
+type recordConn struct {
+	driver *recordDriver // 驱动
+}
+
+// Prepare 不支持预处理
+func (conn *recordConn) Prepare(string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+// Close 关闭
+func (conn *recordConn) Close() error {
+	return nil
+}
+
+// Begin 开启事务
+func (conn *recordConn) Begin() (driver.Tx, error) {
+	return conn, nil
+}
+
+// Commit 提交
+func (conn *recordConn) Commit() error {
+	return nil
+}
+
+// Rollback 回滚
+func (conn *recordConn) Rollback() error {
+	return nil
+}
+
+// ExecContext 执行，建表时记录表
+func (conn *recordConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.driver.record(query, args)
+
+	if strings.HasPrefix(query, "CREATE TABLE") {
+		name := strings.Trim(strings.Fields(query)[2], "`")
+
+		conn.driver.mutex.Lock()
+		conn.driver.tables[name] = true
+		conn.driver.mutex.Unlock()
+	}
+
+	return recordResult{}, nil
+}
+
+// 执行结果
+type recordResult struct{}
+
+// LastInsertId 自增 id
+func (recordResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+// RowsAffected 影响行数
+func (recordResult) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+// QueryContext 查询
+func (conn *recordConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	conn.driver.record(query, args)
+
+	conn.driver.mutex.Lock()
+	defer conn.driver.mutex.Unlock()
+
+	switch {
+	case query == "SELECT DATABASE()":
+		return &recordRows{columns: []string{"DATABASE()"}, values: [][]driver.Value{{"test"}}}, nil
+	case strings.Contains(query, "information_schema.tables"):
+		var count int64
+		if conn.driver.tables[args[1].Value.(string)] {
+			count = 1
+		}
+
+		return &recordRows{columns: []string{"count(*)"}, values: [][]driver.Value{{count}}}, nil
+	case strings.HasPrefix(query, "SELECT count(*)"):
+		return &recordRows{columns: []string{"count(*)"}, values: [][]driver.Value{{conn.driver.count}}}, nil
+	default:
+		return &recordRows{columns: []string{"id"}}, nil
+	}
+}
+
+// 结果
+type recordRows struct {
+	columns []string         // 列
+	values  [][]driver.Value // 行
+}
+
+// Columns 列
+func (rows *recordRows) Columns() []string {
+	return rows.columns
+}
+
+// Close 关闭
+func (rows *recordRows) Close() error {
+	return nil
+}
+
+// Next 下一行
+func (rows *recordRows) Next(dest []driver.Value) error {
+	if len(rows.values) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, rows.values[0])
+	rows.values = rows.values[1:]
+	return nil
+}
+
+// 创建连接记录驱动的仓库
+func newRecordRepository(t *testing.T, recordDriver *recordDriver) *repository {
+	db, err := gorm.Open(gormmysql.New(gormmysql.Config{
+		Conn:                      sql.OpenDB(recordConnector{driver: recordDriver}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		Logger: logger.Discard,
+		NamingStrategy: schema.NamingStrategy{
+			TablePrefix:   "tbl_",
+			SingularTable: true,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newRepository(&mysql.Mysql{DB: db})
+}
+
+// 连接器
+type recordConnector struct {
+	driver *recordDriver // 驱动
+}
+
+// Connect 连接
+func (connector recordConnector) Connect(context.Context) (driver.Conn, error) {
+	return connector.driver.Open("")
+}
+
+// Driver 驱动
+func (connector recordConnector) Driver() driver.Driver {
+	return connector.driver
+}
+
+func TestRepository(t *testing.T) {
+	recordDriver := &recordDriver{
+		tables: make(map[string]bool),
+		count:  25,
+	}
+	repository := newRecordRepository(t, recordDriver)
+
+	newAccessLog := func(createdAt string) AccessLog {
+		accessLog := AccessLog{}
+		accessLog.CreatedAt, _ = time.Parse(time.DateOnly, createdAt)
+		return accessLog
+	}
+
+	// 按月分表，首次写入时建表与索引
+	for i := 0; i < 2; i++ {
+		if err := repository.save([]AccessLog{newAccessLog("2024-07-31"), newAccessLog("2024-08-01")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{"tbl_access_log_202407", "tbl_access_log_202408"} {
+		if creates := recordDriver.find("CREATE TABLE `" + name + "`"); len(creates) != 1 {
+			t.Fatalf("%s should be created once, got %v", name, creates)
+		}
+
+		if indexes := recordDriver.find("ON " + name + " "); len(indexes) != 3 {
+			t.Fatalf("%s should have 3 indexes, got %v", name, indexes)
+		}
+
+		if inserts := recordDriver.find("INSERT INTO `" + name + "`"); len(inserts) != 2 {
+			t.Fatalf("%s should be inserted twice, got %v", name, inserts)
+		}
+	}
+
+	// 跨月分页，跳过不存在的分表
+	begin, _ := time.Parse(time.DateOnly, "2024-06-15")
+	end, _ := time.Parse(time.DateOnly, "2024-08-10")
+	accessLogs, pagination, err := repository.find(Query{
+		UserId: "user-1",
+		Uri:    "100%",
+		Begin:  begin,
+		End:    end,
+		Page:   hypersonic.Page{Page: 2, PageSize: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(accessLogs) != 0 || pagination.TotalSize != 25 || pagination.TotalPage != 3 || pagination.Page.Page != 2 {
+		t.Fatalf("unexpected pagination %+v", pagination)
+	}
+
+	selects := recordDriver.find("UNION ALL")
+	if len(selects) != 2 {
+		t.Fatalf("unexpected union queries %v", selects)
+	}
+
+	for _, expected := range []string{
+		"FROM `tbl_access_log_202407` WHERE",
+		"FROM `tbl_access_log_202408` WHERE",
+		"user_id = ? AND uri LIKE ?",
+		"ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		`user-1 %100\%% 2024-06-15`,
+		`user-1 %100\%% 10 10]`,
+	} {
+		if !strings.Contains(selects[1], expected) {
+			t.Fatalf("query missing %s\n%s", expected, selects[1])
+		}
+	}
+
+	if strings.Contains(selects[1], "tbl_access_log_202406") {
+		t.Fatalf("missing table should be skipped\n%s", selects[1])
+	}
+}
@@ -7,29 +7,79 @@
 package hypersonic
 
 import (
+	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"framework/pkg/redis"
 	"framework/pkg/utils"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"log/slog"
+	"net/http"
 	"strings"
 	"time"
 )
 
+// TokenStorageType 令牌存储类型
+type TokenStorageType string
+
+const (
+	TokenStorageJwt   TokenStorageType = "Jwt"   // jwt
+	TokenStorageRedis TokenStorageType = "Redis" // redis 不透明令牌
+)
+
+// TokenAlgorithm jwt 签名算法
+type TokenAlgorithm string
+
+const (
+	TokenHS256 TokenAlgorithm = "HS256" // HMAC SHA256
+	TokenRS256 TokenAlgorithm = "RS256" // RSA SHA256
+	TokenEdDSA TokenAlgorithm = "EdDSA" // Ed25519
+)
+
+// TokenKey jwt 密钥
+type TokenKey struct {
+	Id         string // 密钥 id，写入 jwt 首部 kid
+	Secret     []byte // HS256 密钥
+	PrivateKey []byte // RS256、EdDSA PEM 私钥，仅签发密钥需要
+	PublicKey  []byte // RS256、EdDSA PEM 公钥
+}
+
+// TokenConfig 令牌配置
+type TokenConfig struct {
+	Storage        TokenStorageType // 存储类型，默认 jwt
+	Algorithm      TokenAlgorithm   // jwt 签名算法，默认 HS256
+	Keys           []TokenKey       // jwt 密钥，第一个用于签发，其余仅用于验证以便轮换，开发模式未配置 HS256 密钥时使用随机密钥
+	Redis          *redis.Redis     // redis，redis 存储必填，配置后启用会话、刷新与吊销
+	CookieName     string           // cookie 名称，默认 token
+	CookieDomain   string           // cookie 域名
+	CookieSecure   bool             // cookie 仅 https
+	CookieSameSite http.SameSite    // cookie SameSite
+
+	AccessMaxAge      time.Duration // 访问令牌有效期，默认 2 小时，仅用于 Login
+	RefreshMaxAge     time.Duration // 刷新令牌有效期，默认 30 天
+	RefreshCookieName string        // 刷新令牌 cookie 名称，默认 token_refresh
+}
+
 // Cookie cookie
 type Cookie struct {
-	ctx *gin.Context
+	ctx    *gin.Context // gin 上下文
+	config *TokenConfig // 令牌配置
 }
 
 // 创建 cookie
-func newCookie(ctx *gin.Context) Cookie {
-	return Cookie{ctx: ctx}
+func newCookie(ctx *gin.Context, config *TokenConfig) Cookie {
+	return Cookie{
+		ctx:    ctx,
+		config: config,
+	}
 }
 
 // Set 设置
 func (cookie Cookie) Set(key string, value string, maxAge int) {
-	cookie.ctx.SetCookie(key, value, maxAge, "/", "", false, true)
+	cookie.ctx.SetSameSite(cookie.config.CookieSameSite)
+	cookie.ctx.SetCookie(key, value, maxAge, "/", cookie.config.CookieDomain, cookie.config.CookieSecure, true)
 }
 
 // Get 获取
@@ -55,146 +105,363 @@ func (cookie Cookie) Get(key string) string {
 
 // Delete 删除
 func (cookie Cookie) Delete(key string) {
-	cookie.ctx.SetCookie(key, "", -1, "/", "", false, true)
+	cookie.ctx.SetSameSite(cookie.config.CookieSameSite)
+	cookie.ctx.SetCookie(key, "", -1, "/", cookie.config.CookieDomain, cookie.config.CookieSecure, true)
+}
+
+// 令牌负荷
+type tokenPayload struct {
+	UserId     string `json:"userId"`               // 用户 id
+	SessionId  string `json:"sessionId,omitempty"`  // 会话 id
+	Generation int64  `json:"generation,omitempty"` // 用户代数
 }
 
 // 令牌存储
 type tokenStorage interface {
-	Set(payload any, maxAge time.Duration) (value string, err error) // 设置
-	Get(key string) (payload any, ttl time.Duration, err error)      // 获取
+	Set(payload tokenPayload, maxAge time.Duration) (value string, err error) // 设置
+	Get(value string) (payload tokenPayload, ttl time.Duration, err error)    // 获取
+	Delete(value string)                                                      // 删除
 }
 
 // jwt 负荷
 type jwtPayload struct {
-	jwt.StandardClaims     // 标准
-	payload            any // 负荷
+	jwt.StandardClaims              // 标准
+	Payload            tokenPayload `json:"payload"` // 负荷
+}
+
+// jwt 密钥
+type jwtKey struct {
+	id        string // 密钥 id
+	signKey   any    // 签名密钥
+	verifyKey any    // 验证密钥
 }
 
 // jwt 令牌存储
 type jwtTokenStorage struct {
-	ctx    *gin.Context // gin 上下文
-	Secret []byte       // jwt 私钥
+	method jwt.SigningMethod // 签名算法
+	keys   []jwtKey          // 密钥，第一个用于签发
 }
 
 // 创建 jwt 令牌存储
-func newJwtTokenStorage(secret []byte) jwtTokenStorage {
-	return jwtTokenStorage{
-		Secret: secret,
+func newJwtTokenStorage(algorithm TokenAlgorithm, keys []TokenKey) (*jwtTokenStorage, error) {
+	storage := &jwtTokenStorage{
+		keys: make([]jwtKey, 0, len(keys)),
+	}
+
+	switch algorithm {
+	case TokenHS256:
+		storage.method = jwt.SigningMethodHS256
+	case TokenRS256:
+		storage.method = jwt.SigningMethodRS256
+	case TokenEdDSA:
+		storage.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("token algorithm %s not supported", algorithm)
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("token keys cannot be empty")
 	}
+
+	for index, key := range keys {
+		var k = jwtKey{id: key.Id}
+		var err error
+
+		switch algorithm {
+		case TokenHS256:
+			if len(key.Secret) == 0 {
+				return nil, fmt.Errorf("token key %d secret cannot be empty", index)
+			}
+			k.signKey, k.verifyKey = key.Secret, key.Secret
+		case TokenRS256:
+			if k.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(key.PublicKey); err != nil {
+				return nil, err
+			}
+			if index == 0 {
+				if k.signKey, err = jwt.ParseRSAPrivateKeyFromPEM(key.PrivateKey); err != nil {
+					return nil, err
+				}
+			}
+		case TokenEdDSA:
+			if k.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(key.PublicKey); err != nil {
+				return nil, err
+			}
+			if index == 0 {
+				if k.signKey, err = jwt.ParseEdPrivateKeyFromPEM(key.PrivateKey); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		storage.keys = append(storage.keys, k)
+	}
+
+	return storage, nil
 }
 
 // Set 设置
-func (jwtTokenStorage jwtTokenStorage) Set(payload any, maxAge time.Duration) (value string, err error) {
-	return jwt.NewWithClaims(jwt.SigningMethodHS256, jwtPayload{
+func (jwtTokenStorage *jwtTokenStorage) Set(payload tokenPayload, maxAge time.Duration) (value string, err error) {
+	key := jwtTokenStorage.keys[0]
+
+	token := jwt.NewWithClaims(jwtTokenStorage.method, jwtPayload{
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Unix() + maxAge.Milliseconds(),
+			IssuedAt:  time.Now().Unix(),
+			ExpiresAt: time.Now().Add(maxAge).Unix(),
 		},
-		payload: payload,
-	}).SignedString(jwtTokenStorage.Secret)
+		Payload: payload,
+	})
+
+	if key.id != "" {
+		token.Header["kid"] = key.id
+	}
+
+	return token.SignedString(key.signKey)
 }
 
-// Get 设置
-func (jwtTokenStorage jwtTokenStorage) Get(key string) (payload any, ttl time.Duration, err error) {
-	token, err := jwt.ParseWithClaims(key, &jwtPayload{}, func(token *jwt.Token) (any, error) {
-		return jwtTokenStorage.Secret, nil
-	})
+// Get 获取
+func (jwtTokenStorage *jwtTokenStorage) Get(value string) (payload tokenPayload, ttl time.Duration, err error) {
+	parser := jwt.Parser{
+		ValidMethods: []string{jwtTokenStorage.method.Alg()},
+	}
+
+	token, err := parser.ParseWithClaims(value, &jwtPayload{}, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return jwtTokenStorage.keys[0].verifyKey, nil
+		}
 
-	if token != nil {
-		if claims, ok := token.Claims.(*jwtPayload); ok && token.Valid {
-			payload = &claims.payload
-			ttl = time.Duration(claims.ExpiresAt-time.Now().Unix()) * time.Millisecond
-			err = nil
-			return
+		for _, key := range jwtTokenStorage.keys {
+			if key.id == kid {
+				return key.verifyKey, nil
+			}
 		}
+
+		return nil, fmt.Errorf("token kid %s not found", kid)
+	})
+	if err != nil {
+		return tokenPayload{}, 0, err
+	}
+
+	if claims, ok := token.Claims.(*jwtPayload); ok && token.Valid {
+		return claims.Payload, time.Until(time.Unix(claims.ExpiresAt, 0)), nil
 	}
 
-	return nil, 0, err
+	return tokenPayload{}, 0, errors.New("token invalid")
 }
 
+// Delete 删除，jwt 无状态无需处理
+func (jwtTokenStorage *jwtTokenStorage) Delete(_ string) {}
+
 // redis 令牌存储
 type redisTokenStorage struct {
 	redis *redis.Redis // 缓存
 }
 
 // 创建 redis 令牌存储
-func newRedisTokenStorage(redis *redis.Redis) redisTokenStorage {
-	return redisTokenStorage{
-		redis: redis,
+func newRedisTokenStorage(redis *redis.Redis) (*redisTokenStorage, error) {
+	if redis == nil {
+		return nil, errors.New("token redis cannot be nil")
 	}
+
+	return &redisTokenStorage{
+		redis: redis,
+	}, nil
+}
+
+// 获取格式化 Key
+func (redisTokenStorage *redisTokenStorage) getFormatKey(value string) string {
+	return fmt.Sprintf("Token:%s", value)
 }
 
 // Set 设置
-func (redisTokenStorage redisTokenStorage) Set(payload any, maxAge time.Duration) (value string, err error) {
-	key := utils.NanoId(12)
-	payloadBytes, _ := json.MarshalIndent(payload, "", "\t")
-	redisTokenStorage.redis.Set(key, payloadBytes, maxAge)
-	return key, nil
+func (redisTokenStorage *redisTokenStorage) Set(payload tokenPayload, maxAge time.Duration) (value string, err error) {
+	value = utils.NanoId(32)
+	redisTokenStorage.redis.SetJson(redisTokenStorage.getFormatKey(value), payload, maxAge)
+	return value, nil
 }
 
 // Get 获取
-func (redisTokenStorage redisTokenStorage) Get(value string) (payload any, ttl time.Duration, err error) {
-	if b, ok := redisTokenStorage.redis.Get(value); !ok {
-		return nil, 0, errors.New("empty value")
+func (redisTokenStorage *redisTokenStorage) Get(value string) (payload tokenPayload, ttl time.Duration, err error) {
+	formatKey := redisTokenStorage.getFormatKey(value)
+
+	if b, ok := redisTokenStorage.redis.Get(formatKey); !ok {
+		return tokenPayload{}, 0, errors.New("empty value")
 	} else {
-		if ttl, ok = redisTokenStorage.redis.GetTtl(value); !ok {
-			return nil, 0, errors.New("key expired")
+		if ttl, ok = redisTokenStorage.redis.GetTtl(formatKey); !ok {
+			return tokenPayload{}, 0, errors.New("key expired")
 		}
 
 		if err = json.Unmarshal(b, &payload); err != nil {
-			return nil, 0, err
+			return tokenPayload{}, 0, err
 		} else {
 			return payload, ttl, nil
 		}
 	}
 }
 
-// 令牌负荷
-type tokenPayload struct {
-	UserId string `json:"userId"` // 用户 id
+// Delete 删除
+func (redisTokenStorage *redisTokenStorage) Delete(value string) {
+	redisTokenStorage.redis.Del(redisTokenStorage.getFormatKey(value))
+}
+
+// 创建令牌存储，开发模式未配置密钥时使用随机密钥，重启后令牌失效，
+// 非开发模式必须配置密钥，否则各实例签名不一致
+func newTokenStorage(config *TokenConfig, isDev bool) (tokenStorage, error) {
+	if config.CookieName == "" {
+		config.CookieName = tokenTag
+	}
+
+	switch config.Storage {
+	case TokenStorageRedis:
+		return newRedisTokenStorage(config.Redis)
+	case TokenStorageJwt, "":
+		if config.Algorithm == "" {
+			config.Algorithm = TokenHS256
+		}
+
+		if len(config.Keys) == 0 && config.Algorithm == TokenHS256 {
+			if !isDev {
+				return nil, errors.New("token keys must be configured outside dev mode")
+			}
+
+			secret := make([]byte, 32)
+			if _, err := rand.Read(secret); err != nil {
+				return nil, err
+			}
+
+			config.Keys = []TokenKey{{Secret: secret}}
+			slog.Warn("token keys not configured, using a random secret")
+		}
+
+		return newJwtTokenStorage(config.Algorithm, config.Keys)
+	default:
+		return nil, fmt.Errorf("token storage %s not supported", config.Storage)
+	}
 }
 
 // Token 令牌
 type Token struct {
-	ctx    *gin.Context
-	cookie Cookie
-	saver  tokenStorage
+	ctx      *gin.Context   // gin 上下文
+	cookie   Cookie         // cookie
+	storage  tokenStorage   // 存储
+	sessions *tokenSessions // 会话
+	config   *TokenConfig   // 配置
 }
 
-const tokenTag = "token"                                 // 令牌标志
-const jwtSecret = "9B95050D-B322-49E2-BDF2-3FC9A834F779" // jwt 密钥
+const tokenTag = "token"   // 令牌标志
+const userIdTag = "USERID" // 用户 id tag
 
 // 创建令牌
-func newToken(ctx *gin.Context) Token {
+func newToken(ctx *gin.Context, hypersonic *Hypersonic) Token {
 	return Token{
-		ctx:    ctx,
-		cookie: newCookie(ctx),
-		saver:  newJwtTokenStorage([]byte(jwtSecret)),
+		ctx:      ctx,
+		cookie:   newCookie(ctx, &hypersonic.tokenConfig),
+		storage:  hypersonic.tokenStorage,
+		sessions: hypersonic.tokenSessions,
+		config:   &hypersonic.tokenConfig,
 	}
 }
 
-// SetUserId 设置用户 id
-func (token Token) SetUserId(userId string, maxAge time.Duration) {
-	if value, err := token.saver.Set(tokenPayload{UserId: userId}, maxAge); err != nil {
+// 签发访问令牌
+func (token Token) issue(payload tokenPayload, maxAge time.Duration) string {
+	if token.sessions != nil {
+		payload.Generation = token.sessions.generation(payload.UserId)
+	}
+
+	if value, err := token.storage.Set(payload, maxAge); err != nil {
 		panic(err.Error())
 	} else {
-		token.cookie.Set(tokenTag, value, int(maxAge.Milliseconds()))
-		token.ctx.Set(tokenTag, value)
+		token.cookie.Set(token.config.CookieName, value, int(maxAge.Seconds()))
+		token.ctx.Set(userIdTag, payload.UserId)
+		return value
+	}
+}
+
+// 获取当前令牌负荷
+func (token Token) getPayload() *tokenPayload {
+	value := token.cookie.Get(token.config.CookieName)
+	if value == "" {
+		return nil
+	}
+
+	payload, ttl, err := token.storage.Get(value)
+	if err != nil || ttl <= 0 {
+		return nil
+	}
+
+	if token.sessions != nil && !token.sessions.valid(payload) {
+		return nil
+	}
+
+	return &payload
+}
+
+// SetUserId 设置用户 id，不创建会话，无法刷新
+func (token Token) SetUserId(userId string, maxAge time.Duration) {
+	token.issue(tokenPayload{UserId: userId}, maxAge)
+}
+
+// Login 登录并创建会话，签发访问令牌与刷新令牌，device 为设备信息，如 mysql.ModelRefer
+func (token Token) Login(userId string, device any) TokenPair {
+	if token.sessions == nil {
+		panic(errSessionsDisabled.Error())
+	}
+
+	deviceBytes, _ := json.Marshal(device)
+	record := token.sessions.create(Session{
+		UserId:    userId,
+		Device:    deviceBytes,
+		Ip:        token.ctx.ClientIP(),
+		UserAgent: token.ctx.Request.UserAgent(),
+	})
+
+	return token.pair(record)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，刷新令牌仅能使用一次
+func (token Token) Refresh() TokenPair {
+	if token.sessions == nil {
+		panic(errSessionsDisabled.Error())
+	}
+
+	refreshToken := token.cookie.Get(token.config.RefreshCookieName)
+	if refreshToken == "" {
+		panic(NewError(CodeNoAuth))
 	}
+
+	record := token.sessions.refresh(refreshToken)
+	if record == nil {
+		token.cookie.Delete(token.config.RefreshCookieName)
+		panic(NewError(CodeNoAuth))
+	}
+
+	return token.pair(*record)
 }
 
-// GetUserId 获取用户 id
+// 签发令牌对
+func (token Token) pair(record sessionRecord) TokenPair {
+	accessToken := token.issue(tokenPayload{
+		UserId:    record.UserId,
+		SessionId: record.Id,
+	}, token.config.AccessMaxAge)
+
+	token.cookie.Set(token.config.RefreshCookieName, record.RefreshToken, int(token.config.RefreshMaxAge.Seconds()))
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: record.RefreshToken,
+		ExpiresIn:    int64(token.config.AccessMaxAge.Seconds()),
+	}
+}
+
+// GetUserId 获取用户 id，令牌无效、过期或已吊销时返回 nil
 func (token Token) GetUserId() *string {
-	userId := token.ctx.GetString(tokenTag)
+	userId := token.ctx.GetString(userIdTag)
 
 	if userId == "" {
-		if value := token.cookie.Get(tokenTag); value != "" {
-			if payload, ttl, err := token.saver.Get(value); err != nil {
-				panic(err.Error())
-			} else if ttl > 0 {
-				if p, ok := payload.(*tokenPayload); ok {
-					userId = p.UserId
-				}
-			}
+		if payload := token.getPayload(); payload != nil {
+			userId = payload.UserId
+			token.ctx.Set(userIdTag, userId)
 		}
 	}
 
@@ -214,9 +481,27 @@ func (token Token) MustGetUserId() string {
 	}
 }
 
-// DeleteUserId 删除用户 id
+// GetSessionId 获取当前会话 id，通过 SetUserId 签发的令牌没有会话
+func (token Token) GetSessionId() *string {
+	if payload := token.getPayload(); payload != nil && payload.SessionId != "" {
+		return &payload.SessionId
+	}
+
+	return nil
+}
+
+// DeleteUserId 删除用户 id，存在会话时一并吊销
 func (token Token) DeleteUserId() {
-	token.cookie.Delete(tokenTag)
+	if payload := token.getPayload(); payload != nil && payload.SessionId != "" && token.sessions != nil {
+		token.sessions.revoke(payload.UserId, payload.SessionId)
+	}
+
+	if value := token.cookie.Get(token.config.CookieName); value != "" {
+		token.storage.Delete(value)
+	}
+
+	token.cookie.Delete(token.config.CookieName)
+	token.cookie.Delete(token.config.RefreshCookieName)
 }
 
 // Filter 认证中间件
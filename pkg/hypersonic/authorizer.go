@@ -0,0 +1,98 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-19 15:26:50
+ */
+
+package hypersonic
+
+import (
+	"slices"
+)
+
+// Grants 授权
+type Grants struct {
+	Roles       []string `json:"roles"`       // 角色
+	Permissions []string `json:"permissions"` // 权限
+}
+
+// HasRole 是否拥有角色
+func (grants Grants) HasRole(role string) bool {
+	return slices.Contains(grants.Roles, role)
+}
+
+// HasPermission 是否拥有权限
+func (grants Grants) HasPermission(permission string) bool {
+	return slices.Contains(grants.Permissions, permission)
+}
+
+// 是否满足，角色满足任意一个，权限需全部满足
+func (grants Grants) satisfy(roles []string, permissions []string) bool {
+	if len(roles) > 0 && !slices.ContainsFunc(roles, grants.HasRole) {
+		return false
+	}
+
+	for _, permission := range permissions {
+		if !grants.HasPermission(permission) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Authorizer 授权者
+type Authorizer interface {
+	GetGrants(tenantId string, userId string) Grants // 获取用户在租户下的授权
+}
+
+// TenantFunc 租户解析，结果须为用户所属租户，配置授权者时必填
+type TenantFunc func(req *Request) string
+
+// HeaderTenantFunc 从首部 X-Tenant-Id 解析租户，首部由客户端控制，
+// Authorizer 须校验用户属于该租户，如仅返回用户在该租户下的授权，否则用户可访问任意租户
+func HeaderTenantFunc(req *Request) string {
+	if tenantId := req.GetHeader("X-Tenant-Id"); tenantId != nil {
+		return *tenantId
+	}
+
+	return ""
+}
+
+const grantsTag = "GRANTS" // 授权 tag
+
+// GetTenantId 获取租户 id，未配置租户解析时为空
+func (req *Request) GetTenantId() string {
+	if req.hypersonic.tenantFunc == nil {
+		return ""
+	}
+
+	return req.hypersonic.tenantFunc(req)
+}
+
+// GetGrants 获取当前用户授权，未登录时抛出 CodeNoAuth
+func (req *Request) GetGrants() Grants {
+	if grants, ok := req.ctx.Get(grantsTag); ok {
+		return grants.(Grants)
+	}
+
+	if req.hypersonic.authorizer == nil {
+		panic("hypersonic authorizer not configured")
+	}
+
+	grants := req.hypersonic.authorizer.GetGrants(req.GetTenantId(), req.Token.MustGetUserId())
+	req.ctx.Set(grantsTag, grants)
+
+	return grants
+}
+
+// 校验角色与权限，不满足时抛出 CodeForbidden
+func (req *Request) authorize(roles []string, permissions []string) {
+	if len(roles) == 0 && len(permissions) == 0 {
+		return
+	}
+
+	if !req.GetGrants().satisfy(roles, permissions) {
+		panic(NewError(CodeForbidden))
+	}
+}
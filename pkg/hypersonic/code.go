@@ -0,0 +1,210 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-14 09:52:16
+ */
+
+package hypersonic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CodeInfo 错误码信息
+type CodeInfo struct {
+	Code    Code   // 错误码
+	Status  int    // http 状态
+	Message string // 默认消息，缺少翻译时使用，可含格式化参数，如 %d
+}
+
+// CodeRegistry 错误码注册表，包级函数使用全局注册表，测试可创建独立注册表避免影响其它测试
+type CodeRegistry struct {
+	mutex *sync.RWMutex     // 锁
+	codes map[Code]CodeInfo // 错误码
+}
+
+// NewCodeRegistry 创建错误码注册表，含内置错误码
+func NewCodeRegistry() *CodeRegistry {
+	codeRegistry := &CodeRegistry{
+		mutex: &sync.RWMutex{},
+		codes: make(map[Code]CodeInfo),
+	}
+
+	for _, info := range builtinCodes {
+		codeRegistry.Register(info.Code, info.Status, info.Message)
+	}
+
+	return codeRegistry
+}
+
+// 全局错误码注册表
+var defaultCodeRegistry = NewCodeRegistry()
+
+// Register 注册错误码及 http 状态与默认消息，重复注册时 panic
+func (codeRegistry *CodeRegistry) Register(code Code, status int, message string) Code {
+	codeRegistry.mutex.Lock()
+	defer codeRegistry.mutex.Unlock()
+
+	if _, ok := codeRegistry.codes[code]; ok {
+		panic(fmt.Sprintf("code %s already registered", code))
+	}
+
+	codeRegistry.codes[code] = CodeInfo{
+		Code:    code,
+		Status:  status,
+		Message: message,
+	}
+
+	return code
+}
+
+// Get 获取已注册的错误码
+func (codeRegistry *CodeRegistry) Get(code Code) (CodeInfo, bool) {
+	codeRegistry.mutex.RLock()
+	defer codeRegistry.mutex.RUnlock()
+
+	info, ok := codeRegistry.codes[code]
+	return info, ok
+}
+
+// Codes 已注册的错误码，按错误码排序
+func (codeRegistry *CodeRegistry) Codes() []CodeInfo {
+	codeRegistry.mutex.RLock()
+	defer codeRegistry.mutex.RUnlock()
+
+	codes := make([]CodeInfo, 0, len(codeRegistry.codes))
+	for _, info := range codeRegistry.codes {
+		codes = append(codes, info)
+	}
+
+	sort.Slice(codes, func(i, j int) bool {
+		return codes[i].Code < codes[j].Code
+	})
+
+	return codes
+}
+
+// 翻译错误码，缺少翻译时使用注册的默认消息
+func (codeRegistry *CodeRegistry) translate(i18n *I18n, lang string, code Code) (string, bool) {
+	if value, found := i18n.t(lang, string(code)); found {
+		return value, true
+	}
+
+	if info, ok := codeRegistry.Get(code); ok && info.Message != "" {
+		return info.Message, true
+	}
+
+	return i18n.t(lang, string(code))
+}
+
+// 各语言缺少翻译的错误码
+func (codeRegistry *CodeRegistry) missing(i18n *I18n) map[Lang][]CodeInfo {
+	missing := make(map[Lang][]CodeInfo)
+
+	for _, lang := range i18n.Langs() {
+		for _, info := range codeRegistry.Codes() {
+			if _, found := i18n.t(string(lang), string(info.Code)); !found {
+				missing[lang] = append(missing[lang], info)
+			}
+		}
+	}
+
+	return missing
+}
+
+// Check 检查已注册的错误码在各语言均有翻译，用于测试
+func (codeRegistry *CodeRegistry) Check(i18n *I18n) error {
+	missing := codeRegistry.missing(i18n)
+
+	var errs []error
+	for _, lang := range i18n.Langs() {
+		if len(missing[lang]) == 0 {
+			continue
+		}
+
+		codes := make([]string, 0, len(missing[lang]))
+		for _, info := range missing[lang] {
+			codes = append(codes, string(info.Code))
+		}
+
+		errs = append(errs, fmt.Errorf("i18n %s missing codes: %s", lang, strings.Join(codes, ", ")))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stubs 生成各语言缺少的错误码翻译，值为默认消息，可追加到对应语言的 toml
+func (codeRegistry *CodeRegistry) Stubs(i18n *I18n) map[Lang]string {
+	stubs := make(map[Lang]string)
+
+	for lang, infos := range codeRegistry.missing(i18n) {
+		builder := strings.Builder{}
+		for _, info := range infos {
+			message := info.Message
+			if message == "" {
+				message = string(info.Code)
+			}
+
+			key := string(info.Code)
+			if strings.Trim(key, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789_-") != "" {
+				key = strconv.Quote(key)
+			}
+
+			builder.WriteString(key + " = " + strconv.Quote(message) + "\n")
+		}
+
+		stubs[lang] = builder.String()
+	}
+
+	return stubs
+}
+
+// RegisterCode 在全局注册表注册错误码及 http 状态与默认消息，用于包级变量，重复注册时 panic，
+// 如 var CodeUserLocked = hypersonic.RegisterCode("UserLocked", http.StatusLocked, "user locked")
+func RegisterCode(code Code, status int, message string) Code {
+	return defaultCodeRegistry.Register(code, status, message)
+}
+
+// GetCode 获取全局注册表已注册的错误码
+func GetCode(code Code) (CodeInfo, bool) {
+	return defaultCodeRegistry.Get(code)
+}
+
+// Codes 全局注册表已注册的错误码，按错误码排序
+func Codes() []CodeInfo {
+	return defaultCodeRegistry.Codes()
+}
+
+// CheckCodes 检查全局注册表的错误码在各语言均有翻译，用于测试，如 if err := hypersonic.CheckCodes(i18n); err != nil { t.Fatal(err) }
+func CheckCodes(i18n *I18n) error {
+	return defaultCodeRegistry.Check(i18n)
+}
+
+// CodeStubs 生成全局注册表各语言缺少的错误码翻译
+func CodeStubs(i18n *I18n) map[Lang]string {
+	return defaultCodeRegistry.Stubs(i18n)
+}
+
+// 内置错误码
+var builtinCodes = []CodeInfo{
+	{CodeOK, http.StatusOK, "OK"},
+	{CodeNoContent, http.StatusNoContent, "No content"},
+	{CodeNotFound, http.StatusNotFound, "Not found"},
+	{CodeNotMatch, http.StatusBadRequest, "Not match"},
+	{CodeNotImplemented, http.StatusNotImplemented, "Not implemented"},
+	{CodeParameterError, http.StatusBadRequest, "Parameter error"},
+	{CodeConflict, http.StatusConflict, "Conflict"},
+	{CodeThirdPartyError, http.StatusBadGateway, "Third party error"},
+	{CodeInternalError, http.StatusInternalServerError, "Internal error"},
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "Method not allowed"},
+	{CodeRateLimit, http.StatusTooManyRequests, "Rate limit"},
+	{CodeForbidden, http.StatusForbidden, "Forbidden"},
+	{CodeNoAuth, http.StatusUnauthorized, "No auth"},
+	{CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "Request body too large, max %d bytes"},
+}
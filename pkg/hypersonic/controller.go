@@ -8,6 +8,9 @@ package hypersonic
 
 import (
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"slices"
 )
 
 // InvokeFunc 路由处理回调
@@ -15,45 +18,121 @@ type InvokeFunc func(req *Request) (*Data, *Error)
 
 // Router 路由
 type Router struct {
-	HttpMethod   string     // 方法类型 GET、POST、PUT、DELETE
+	HttpMethod   string     // 方法类型 GET、HEAD、POST、PUT、PATCH、DELETE、OPTIONS 或 MethodAny
 	RelativePath string     // 相对路径
 	Limits       []Limit    // 限制调用
+	Roles        []string   // 所需角色，满足任意一个
+	Permissions  []string   // 所需权限，需全部满足
 	InvokeFunc   InvokeFunc // 路由处理回调
+	EventFunc    EventFunc  // SSE 路由处理回调，替代 InvokeFunc，仅支持 GET
+	SocketFunc   SocketFunc // WebSocket 路由处理回调，替代 InvokeFunc，仅支持 GET
+	Handler      *Handler   // 类型化路由处理，设置后替代 InvokeFunc、Parameter、Result
+	Parameter    any        // 参数类型，可选，用于生成文档，如 parameter{}
+	Result       any        // 结果类型，可选，用于生成文档，如 data{}
+	MaxBodySize  int64      // 最大请求体字节数，0 时使用 Config.MaxBodySize，小于 0 时不限制
+	Stream       bool       // 流式上传，不缓存请求体，通过 Request.GetMultipartReader 或 GetBodyReader 读取
 }
 
-// 检查限制调用
-func (router Router) checkLimit(req *Request, listener Listener) {
+// 使用类型化路由处理
+func (router Router) normalize() Router {
+	if router.Handler != nil {
+		router.InvokeFunc = router.Handler.invokeFunc
+		router.Parameter = router.Handler.parameter
+		router.Result = router.Handler.result
+	}
+
+	if router.EventFunc != nil || router.SocketFunc != nil {
+		if router.InvokeFunc != nil || (router.EventFunc != nil && router.SocketFunc != nil) {
+			panic("hypersonic router can only have one of invoke, event or socket func")
+		}
+
+		if router.HttpMethod != http.MethodGet {
+			panic("hypersonic event and socket router must use GET")
+		}
+
+		return router
+	}
+
+	if router.InvokeFunc == nil {
+		panic("hypersonic router invoke func cannot be nil")
+	}
+
+	return router
+}
+
+// 检查限制调用，响应首部使用剩余次数最少的限制，返回各限制的键
+func (router Router) checkLimit(req *Request) []string {
+	var tightest *LimitInfo
+
+	keys := make([]string, 0, len(router.Limits))
 	for _, limit := range router.Limits {
-		var key string
-
-		switch limit.config.LimitType {
-		case LimitIp:
-			key = req.GetIp()
-		case LimitUserId:
-			key = req.GetUri() + "_" + req.Token.MustGetUserId()
-		default:
-			panic(NewError(CodeInternalError))
+		key := limit.getKey(req)
+		keys = append(keys, key)
+
+		// 仅统计错误的限制先查询，返回错误后计数
+		cost := int64(1)
+		if len(limit.config.ErrorCodes) > 0 {
+			cost = 0
 		}
 
-		if !limit.Add(key) {
-			listener.OnLimit(limit.config.LimitType, req)
-			panic(NewErrorWithArgv(CodeRateLimit))
+		allowed, info := limit.take(key, cost)
+		if !allowed {
+			req.setLimitHeaders(info, true)
+			req.hypersonic.onLimit(req, info)
+			panic(NewErrorWithArgv(limit.config.Code))
+		}
+
+		if tightest == nil || info.Remaining < tightest.Remaining {
+			tightest = &info
+		}
+	}
+
+	if tightest != nil {
+		req.setLimitHeaders(*tightest, false)
+	}
+
+	return keys
+}
+
+// 路由返回错误时为仅统计错误的限制计数
+func (router Router) countLimitErrors(keys []string, err *Error) {
+	if err == nil {
+		return
+	}
+
+	for i, limit := range router.Limits {
+		if slices.Contains(limit.config.ErrorCodes, err.Code) {
+			limit.take(keys[i], 1)
 		}
 	}
 }
 
 // 执行路由
-func (router Router) run(ctx *gin.Context, i18n *I18n, adapter Listener) {
-	req := newRequest(ctx)
+func (router Router) run(ctx *gin.Context, hypersonic *Hypersonic) {
+	req := newRequest(ctx, hypersonic)
+	req.parameterType = reflect.TypeOf(router.Parameter)
+
+	req.authorize(router.Roles, router.Permissions)
+	limitKeys := router.checkLimit(req)
 
-	router.checkLimit(req, adapter)
-	data, err := router.InvokeFunc(req)
-	req.reply(data, err, i18n, adapter)
+	switch {
+	case router.EventFunc != nil:
+		req.serveEvent(router.EventFunc)
+	case router.SocketFunc != nil:
+		req.serveSocket(router.SocketFunc)
+	default:
+		data, err := router.InvokeFunc(req)
+		router.countLimitErrors(limitKeys, err)
+		req.reply(data, err)
+	}
 }
 
 // Controller 控制器
 type Controller struct {
 	Path              string            // 路径
 	RequestMiddleware RequestMiddleware // 中间件
+	Roles             []string          // 所需角色，满足任意一个
+	Permissions       []string          // 所需权限，需全部满足
 	Routers           []Router          // 路由路径
+	Log               *LogConfig        // 请求日志，覆盖 LogListener 的配置
 }
@@ -0,0 +1,90 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-15 14:20:36
+ */
+
+package hypersonic
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CorsConfig 跨域配置
+type CorsConfig struct {
+	AllowOrigins     []string      // 允许的来源，如 https://example.com，* 为任意，为空时不响应 Access-Control-Allow-Origin
+	AllowHeaders     []string      // 预检允许的请求头，为空时回显 Access-Control-Request-Headers
+	AllowCredentials bool          // 是否允许携带 cookie，须显式列出来源，不能与 * 同时使用
+	MaxAge           time.Duration // 预检缓存时间
+}
+
+// 校验，允许携带 cookie 时任意来源均可携带用户凭证访问
+func (config CorsConfig) validate() error {
+	if config.AllowCredentials && slices.Contains(config.AllowOrigins, "*") {
+		return errors.New("cors allow credentials requires explicit allow origins")
+	}
+
+	return nil
+}
+
+// 获取允许的来源，不允许时为空
+func (config CorsConfig) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+
+	if slices.Contains(config.AllowOrigins, "*") {
+		return "*"
+	}
+
+	if slices.Contains(config.AllowOrigins, origin) {
+		return origin
+	}
+
+	return ""
+}
+
+// 跨域中间件，响应允许的来源
+func (config CorsConfig) middleware(ctx *gin.Context) {
+	ctx.Writer.Header().Add("Vary", "Origin")
+
+	if origin := config.allowOrigin(ctx.GetHeader("Origin")); origin != "" {
+		ctx.Header("Access-Control-Allow-Origin", origin)
+
+		if config.AllowCredentials {
+			ctx.Header("Access-Control-Allow-Credentials", "true")
+		}
+	}
+}
+
+// 跨域预检，请求含 Origin 与 Access-Control-Request-Method 时响应允许的方法，
+// 来源允许时响应允许的请求头与缓存时间
+func (config CorsConfig) preflight(ctx *gin.Context, methods []string) {
+	if ctx.GetHeader("Origin") == "" || ctx.GetHeader("Access-Control-Request-Method") == "" {
+		return
+	}
+
+	ctx.Header("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+
+	if config.allowOrigin(ctx.GetHeader("Origin")) == "" {
+		return
+	}
+
+	ctx.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+	ctx.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	if len(config.AllowHeaders) > 0 {
+		ctx.Header("Access-Control-Allow-Headers", strings.Join(config.AllowHeaders, ", "))
+	} else if headers := ctx.GetHeader("Access-Control-Request-Headers"); headers != "" {
+		ctx.Header("Access-Control-Allow-Headers", headers)
+	}
+
+	if config.MaxAge > 0 {
+		ctx.Header("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+	}
+}
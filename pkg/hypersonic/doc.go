@@ -0,0 +1,130 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-14 11:02:47
+ */
+
+package hypersonic
+
+import (
+	"framework/pkg/hypersonic/swagger"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// 添加路由文档
+func (hypersonic *Hypersonic) addDoc(basePath string, controller Controller, router Router) {
+	if router.HttpMethod != MethodAny {
+		hypersonic.addOperationDoc(router.HttpMethod, basePath, controller, router)
+		return
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		hypersonic.addOperationDoc(method, basePath, controller, router)
+	}
+}
+
+// 添加操作文档
+func (hypersonic *Hypersonic) addOperationDoc(method string, basePath string, controller Controller, router Router) {
+	openApi := hypersonic.openApi
+	operation := &swagger.Operation{
+		Responses: make(map[string]swagger.Response),
+	}
+
+	// 参数
+	if router.Parameter != nil {
+		parameterType := reflect.TypeOf(router.Parameter)
+
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodDelete, http.MethodOptions:
+			operation.Parameters = openApi.QueryParameters(parameterType)
+		default:
+			if swagger.HasFile(parameterType) {
+				operation.RequestBody = &swagger.RequestBody{
+					Required: true,
+					Content: map[string]swagger.MediaType{
+						"multipart/form-data": {Schema: openApi.FormSchema(parameterType)},
+					},
+				}
+				break
+			}
+
+			operation.Parameters = openApi.TagParameters(parameterType, "form", "query")
+			operation.RequestBody = &swagger.RequestBody{
+				Required: true,
+				Content: map[string]swagger.MediaType{
+					"application/json": {Schema: openApi.SchemaOf(parameterType)},
+				},
+			}
+		}
+
+		operation.Parameters = append(operation.Parameters, openApi.TagParameters(parameterType, "header", "header")...)
+	}
+
+	// 成功结果
+	dataSchema := &swagger.Schema{
+		Type: "object",
+		Properties: map[string]*swagger.Schema{
+			"data":       openApi.SchemaOf(reflect.TypeOf(router.Result)),
+			"pagination": openApi.SchemaOf(reflect.TypeOf(Pagination{})),
+		},
+	}
+
+	switch {
+	case router.EventFunc != nil:
+		operation.Responses["200"] = swagger.Response{
+			Description: http.StatusText(http.StatusOK),
+			Content: map[string]swagger.MediaType{
+				"text/event-stream": {Schema: openApi.SchemaOf(reflect.TypeOf(Message{}))},
+			},
+		}
+	case router.SocketFunc != nil:
+		operation.Responses["101"] = swagger.Response{
+			Description: http.StatusText(http.StatusSwitchingProtocols),
+		}
+	default:
+		operation.Responses["200"] = swagger.Response{
+			Description: http.StatusText(http.StatusOK),
+			Content: map[string]swagger.MediaType{
+				"application/json": {Schema: dataSchema},
+			},
+		}
+	}
+
+	// 错误结果
+	operation.Responses["default"] = swagger.Response{
+		Description: "Error",
+		Content: map[string]swagger.MediaType{
+			"application/json": {Schema: openApi.SchemaOf(reflect.TypeOf(Error{}))},
+		},
+	}
+
+	openApi.AddOperation(method, basePath+controller.Path+router.RelativePath, controller.Path, operation)
+}
+
+// 文档中间件
+func (hypersonic *Hypersonic) swaggerMiddleware(ctx *gin.Context) {
+	path := ctx.Request.URL.Path
+
+	// 非 /doc 开头不是文档
+	if !strings.HasPrefix(path, "/doc/") {
+		return
+	}
+
+	// 过滤掉非法的 /doc/? 路径
+	suffix := filepath.Base(path)
+	if suffix != "doc" && suffix != "doc.json" && suffix != "redoc.js" {
+		ctx.Redirect(http.StatusFound, "/doc")
+		return
+	}
+
+	if suffix == "doc.json" {
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", hypersonic.openApi.Json())
+	} else {
+		data, contentType := swagger.Redoc(suffix)
+		ctx.Data(http.StatusOK, contentType, data)
+	}
+}
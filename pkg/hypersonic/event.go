@@ -0,0 +1,191 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-29 14:03:51
+ */
+
+package hypersonic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 心跳间隔
+const heartbeatInterval = 30 * time.Second
+
+// EventFunc SSE 路由处理回调，返回后断开连接，通常以 stream.Wait() 结束
+type EventFunc func(req *Request, stream *EventStream) *Error
+
+// EventStream SSE 连接
+type EventStream struct {
+	req     *Request           // 请求
+	mutex   sync.Mutex         // 写锁
+	context context.Context    // 上下文，客户端断开或服务关闭时结束
+	cancel  context.CancelFunc // 结束
+}
+
+// Send 发送事件，data 序列化为 json
+func (stream *EventStream) Send(event string, data any) error {
+	dataBytes, ok := data.(json.RawMessage)
+	if !ok {
+		var err error
+		if dataBytes, err = json.Marshal(data); err != nil {
+			return err
+		}
+	}
+
+	return stream.write(fmt.Sprintf("event: %s\ndata: %s\n\n", strings.ReplaceAll(event, "\n", ""), dataBytes))
+}
+
+// 写入并刷新
+func (stream *EventStream) write(frame string) error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+
+	writer := stream.req.ctx.Writer
+	if _, err := writer.WriteString(frame); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return nil
+}
+
+// Done 客户端断开或服务关闭
+func (stream *EventStream) Done() <-chan struct{} {
+	return stream.context.Done()
+}
+
+// Wait 等待客户端断开或服务关闭
+func (stream *EventStream) Wait() *Error {
+	<-stream.Done()
+	return nil
+}
+
+// 投递推送消息并定时发送心跳注释
+func (stream *EventStream) pump(client *hubClient) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stream.Done():
+			return
+		case message := <-client.messages:
+			if stream.Send(message.Event, message.Data) != nil {
+				stream.cancel()
+			}
+		case <-ticker.C:
+			if stream.write(": ping\n\n") != nil {
+				stream.cancel()
+			}
+		}
+	}
+}
+
+// 执行 SSE 路由，连接按用户 id 加入推送中心
+func (req *Request) serveEvent(eventFunc EventFunc) {
+	ctx := req.ctx
+	hub := req.hypersonic.hub
+
+	// 长连接不受服务写超时限制
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Time{})
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	stream := &EventStream{
+		req: req,
+	}
+	stream.context, stream.cancel = context.WithCancel(ctx.Request.Context())
+
+	go func() {
+		select {
+		case <-hub.closed:
+			stream.cancel()
+		case <-stream.Done():
+		}
+	}()
+
+	client := hub.add(req.getHubUserId())
+
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		stream.pump(client)
+	}()
+
+	err := req.invokeStream(func() *Error {
+		return eventFunc(req, stream)
+	})
+
+	hub.remove(client)
+	stream.cancel()
+	waitGroup.Wait()
+
+	// 连接已建立，错误以 error 事件发送
+	if err != nil {
+		err.i18n(string(req.GetLang()), req.hypersonic.i18n, req.hypersonic.codes)
+
+		reply := *err
+		reply.RequestId = req.GetRequestId()
+		_ = stream.Send("error", reply)
+	}
+
+	ctx.Abort()
+	req.hypersonic.onLog(req, streamData(err, "sse"), err)
+}
+
+// 获取推送用户 id，未登录时为空
+func (req *Request) getHubUserId() string {
+	if userId := req.Token.GetUserId(); userId != nil {
+		return *userId
+	}
+
+	return ""
+}
+
+// 执行长连接回调，连接建立后异常不能再由中间件响应
+func (req *Request) invokeStream(streamFunc func() *Error) (err *Error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = req.hypersonic.recoveredError(req, recovered)
+		}
+	}()
+
+	return streamFunc()
+}
+
+// 长连接日志数据
+func streamData(err *Error, kind string) *Data {
+	if err != nil {
+		return nil
+	}
+
+	return &Data{response: upgradeResponse(kind)}
+}
+
+// 长连接响应，仅用于日志
+type upgradeResponse string
+
+// 输出，长连接由路由自行输出
+func (upgradeResponse) render(*gin.Context) *Error {
+	return nil
+}
+
+// String 日志描述
+func (upgradeResponse upgradeResponse) String() string {
+	return fmt.Sprintf("<%s>", string(upgradeResponse))
+}
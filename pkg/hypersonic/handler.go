@@ -0,0 +1,211 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-24 14:27:09
+ */
+
+package hypersonic
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin/binding"
+	"reflect"
+	"strings"
+)
+
+// Handler 类型化路由处理
+type Handler struct {
+	invokeFunc InvokeFunc // 路由处理回调
+	parameter  any        // 参数类型
+	result     any        // 结果类型
+}
+
+// Handle 创建类型化路由处理，自动绑定 path、query、header、body 到 P 并验证，结果 R 包装为 Data，R 为 Data 时直接响应
+//
+// 绑定时依次读取 json 或表单请求体、form 标签的 query、header 标签的首部、uri 标签的路径参数，后者覆盖前者
+func Handle[P any, R any](handleFunc func(req *Request, p *P) (*R, *Error)) *Handler {
+	var p P
+	var r R
+
+	return &Handler{
+		invokeFunc: func(req *Request) (*Data, *Error) {
+			// 限制键读取字段时已绑定
+			param, ok := req.parameter.(*P)
+			if !ok {
+				param = new(P)
+				if err := req.bindParameter(param); err != nil {
+					return nil, err
+				}
+			}
+
+			result, err := handleFunc(req, param)
+			if err != nil {
+				return nil, err
+			}
+
+			// 结果为 Data 时直接响应，如 NewFile、NewRedirect
+			if data, ok := any(result).(*Data); ok {
+				return data, nil
+			}
+
+			return NewData(result, nil), nil
+		},
+		parameter: p,
+		result:    r,
+	}
+}
+
+// Parameter 参数类型
+func (handler *Handler) Parameter() reflect.Type {
+	return reflect.TypeOf(handler.parameter)
+}
+
+// Result 结果类型
+func (handler *Handler) Result() reflect.Type {
+	return reflect.TypeOf(handler.result)
+}
+
+// 绑定参数并验证
+func (req *Request) bindParameter(param any) *Error {
+	// 请求体
+	if isForm(req.ctx) {
+		if err := req.bindForm(param); err != nil {
+			return err
+		}
+	} else if body := getBody(req.ctx); len(body) != 0 {
+		if err := json.Unmarshal(body, param); err != nil {
+			return NewErrorWithMessage(CodeParameterError, err.Error())
+		}
+	}
+
+	// query，仅绑定 form 标签字段，避免按字段名覆盖请求体
+	urlQuery := req.ctx.Request.URL.Query()
+	query := make(map[string][]string)
+	for _, key := range tagNames(reflect.TypeOf(param), "form") {
+		if values, ok := urlQuery[key]; ok {
+			query[key] = values
+		}
+	}
+
+	if err := binding.MapFormWithTag(param, query, "form"); err != nil {
+		return NewErrorWithMessage(CodeParameterError, err.Error())
+	}
+
+	// header
+	header := make(map[string][]string)
+	for _, key := range tagNames(reflect.TypeOf(param), "header") {
+		if values := req.ctx.Request.Header.Values(key); len(values) > 0 {
+			header[key] = values
+		}
+	}
+
+	if err := binding.MapFormWithTag(param, header, "header"); err != nil {
+		return NewErrorWithMessage(CodeParameterError, err.Error())
+	}
+
+	// path
+	uri := make(map[string][]string)
+	for _, p := range req.ctx.Params {
+		uri[p.Key] = []string{p.Value}
+	}
+
+	if err := binding.MapFormWithTag(param, uri, "uri"); err != nil {
+		return NewErrorWithMessage(CodeParameterError, err.Error())
+	}
+
+	// 验证
+	return req.validate(param)
+}
+
+// 获取路由参数字段值，首次调用时绑定 Router.Parameter 并缓存
+func (req *Request) getParameterField(name string) string {
+	if req.parameter == nil {
+		t := req.parameterType
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+
+		if t == nil || t.Kind() != reflect.Struct {
+			panic(NewErrorWithMessage(CodeInternalError, "limit key field requires router parameter"))
+		}
+
+		param := reflect.New(t).Interface()
+		if err := req.bindParameter(param); err != nil {
+			panic(err)
+		}
+
+		req.parameter = param
+	}
+
+	if field, ok := findField(reflect.ValueOf(req.parameter), name); ok {
+		return fmt.Sprint(field.Interface())
+	}
+
+	panic(NewErrorWithMessage(CodeInternalError, "limit key field not found "+name))
+}
+
+// 按标签名或字段名查找字段，包含匿名字段
+func findField(v reflect.Value, name string) (reflect.Value, bool) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}, false
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+
+		if field.Anonymous {
+			if found, ok := findField(v.Field(i), name); ok {
+				return found, true
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Name == name {
+			return v.Field(i), true
+		}
+
+		for _, tagKey := range []string{"json", "form", "uri", "header"} {
+			if tag, _, _ := strings.Cut(field.Tag.Get(tagKey), ","); tag == name {
+				return v.Field(i), true
+			}
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// 获取结构体中声明的标签名称，包含匿名字段
+func tagNames(t reflect.Type, tagKey string) []string {
+	names := make([]string, 0)
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		if name, _, _ := strings.Cut(field.Tag.Get(tagKey), ","); name != "-" && name != "" {
+			names = append(names, name)
+		} else if field.Anonymous || field.Type.Kind() == reflect.Struct {
+			names = append(names, tagNames(field.Type, tagKey)...)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,193 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-29 10:16:27
+ */
+
+package hypersonic
+
+import (
+	"encoding/json"
+	"framework/pkg/redis"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// HubConfig 推送配置
+type HubConfig struct {
+	Redis   *redis.Redis // 缓存，配置后通过 pub/sub 推送到所有实例
+	Channel string       // 频道，默认 Hub
+	Buffer  int          // 每个连接的消息缓冲，默认 64，缓冲满时丢弃
+
+	CheckOrigin func(r *http.Request) bool // WebSocket 来源校验，默认要求与 Host 同源，无 Origin 首部时放行
+}
+
+// Message 推送消息
+type Message struct {
+	Event string          `json:"event"` // 事件
+	Data  json.RawMessage `json:"data"`  // 数据
+}
+
+// 频道消息
+type hubPayload struct {
+	UserIds []string `json:"userIds,omitempty"` // 用户 id，为空时推送到所有已登录连接
+	Message Message  `json:"message"`           // 消息
+}
+
+// 推送连接
+type hubClient struct {
+	userId   string       // 用户 id，未登录时为空
+	messages chan Message // 消息
+}
+
+// 推送中心，按用户 id 推送到 SSE 与 WebSocket 连接
+type hub struct {
+	config  HubConfig                          // 配置
+	mutex   sync.RWMutex                       // 锁
+	clients map[string]map[*hubClient]struct{} // 用户 id 连接
+	closed  chan struct{}                      // 关闭信号
+	once    sync.Once                          // 关闭一次
+	cancel  func() error                       // 取消订阅
+}
+
+// 创建推送中心，配置 redis 时订阅频道
+func newHub(config HubConfig) (*hub, error) {
+	if config.Channel == "" {
+		config.Channel = "Hub"
+	}
+
+	if config.Buffer == 0 {
+		config.Buffer = 64
+	}
+
+	hub := &hub{
+		config:  config,
+		clients: make(map[string]map[*hubClient]struct{}),
+		closed:  make(chan struct{}),
+	}
+
+	if config.Redis != nil {
+		messages, cancel, err := config.Redis.Subscribe(config.Channel)
+		if err != nil {
+			return nil, err
+		}
+
+		hub.cancel = cancel
+		go hub.receive(messages)
+	}
+
+	return hub, nil
+}
+
+// 接收频道消息
+func (hub *hub) receive(messages <-chan []byte) {
+	for message := range messages {
+		var payload hubPayload
+		if err := json.Unmarshal(message, &payload); err != nil {
+			slog.Warn("hypersonic hub invalid payload", "err", err)
+			continue
+		}
+
+		hub.deliver(payload)
+	}
+}
+
+// 投递到本实例连接
+func (hub *hub) deliver(payload hubPayload) {
+	hub.mutex.RLock()
+	defer hub.mutex.RUnlock()
+
+	send := func(clients map[*hubClient]struct{}) {
+		for client := range clients {
+			select {
+			case client.messages <- payload.Message:
+			default:
+				slog.Warn("hypersonic hub client buffer full", "userId", client.userId, "event", payload.Message.Event)
+			}
+		}
+	}
+
+	// 未登录连接以空用户 id 注册，不接收推送
+	if len(payload.UserIds) == 0 {
+		for userId, clients := range hub.clients {
+			if userId != "" {
+				send(clients)
+			}
+		}
+		return
+	}
+
+	for _, userId := range payload.UserIds {
+		if userId != "" {
+			send(hub.clients[userId])
+		}
+	}
+}
+
+// 添加连接
+func (hub *hub) add(userId string) *hubClient {
+	client := &hubClient{
+		userId:   userId,
+		messages: make(chan Message, hub.config.Buffer),
+	}
+
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	if hub.clients[userId] == nil {
+		hub.clients[userId] = make(map[*hubClient]struct{})
+	}
+	hub.clients[userId][client] = struct{}{}
+
+	return client
+}
+
+// 移除连接
+func (hub *hub) remove(client *hubClient) {
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+
+	delete(hub.clients[client.userId], client)
+	if len(hub.clients[client.userId]) == 0 {
+		delete(hub.clients, client.userId)
+	}
+}
+
+// 发布消息
+func (hub *hub) publish(payload hubPayload) {
+	if hub.config.Redis == nil {
+		hub.deliver(payload)
+		return
+	}
+
+	payloadBytes, _ := json.Marshal(payload)
+	hub.config.Redis.Publish(hub.config.Channel, payloadBytes)
+}
+
+// 关闭，断开所有连接并取消订阅
+func (hub *hub) close() {
+	hub.once.Do(func() {
+		close(hub.closed)
+
+		if hub.cancel != nil {
+			_ = hub.cancel()
+		}
+	})
+}
+
+// Publish 推送消息到用户的所有 SSE 与 WebSocket 连接，未指定用户 id 时推送到所有已登录连接，未登录连接不接收推送
+func (hypersonic *Hypersonic) Publish(event string, data any, userIds ...string) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	hypersonic.hub.publish(hubPayload{
+		UserIds: userIds,
+		Message: Message{
+			Event: event,
+			Data:  dataBytes,
+		},
+	})
+}
@@ -7,45 +7,176 @@
 package hypersonic
 
 import (
+	"errors"
+	"framework/pkg/hypersonic/swagger"
+	"framework/pkg/telemetry"
 	"github.com/mattn/go-colorable"
 	"net/http"
-	"strconv"
+	"sync"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Config 配置
 type Config struct {
-	Listener Listener // 适配器
-	I18n     *I18n    // 国际化
-	IsDev    bool     // 是否开发模式
+	Listener Listener     // 适配器
+	I18n     *I18n        // 国际化
+	Lang     LangConfig   // 语言协商
+	IsDev    bool         // 是否开发模式
+	Token    TokenConfig  // 令牌
+	Server   ServerConfig // 服务
+
+	Authorizer Authorizer // 授权者，路由声明角色或权限时必填
+	TenantFunc TenantFunc // 租户解析，配置授权者时必填，如令牌用户所属租户或 HeaderTenantFunc
+
+	MaxBodySize int64   // 最大请求体字节数，默认 32M，小于 0 时不限制
+	Storage     Storage // 文件存储，保存上传文件时必填，如 NewLocalStorage
+
+	Hub HubConfig // 推送，SSE 与 WebSocket 连接按用户 id 推送
+
+	RateLimit      RateLimitConfig // 全局限流
+	TrustedProxies []string        // 可信代理 ip 或 CIDR，仅信任其转发的 X-Forwarded-For、X-Real-IP，默认不信任
+
+	Codes   *CodeRegistry // 错误码注册表，默认为 RegisterCode 注册的全局表
+	Problem ProblemConfig // RFC 7807 错误，默认不启用
+	Cors    CorsConfig    // 跨域，默认预检仅响应允许的方法
+
+	Telemetry   *telemetry.Telemetry // 遥测，配置后记录路由 span 与请求指标
+	MetricsPath string               // 指标地址，如 /metrics，为空时不注册，注册在服务端口且无鉴权，公网服务应以 Telemetry.Handler 在内网端口单独监听
 }
 
 // Hypersonic 服务
 type Hypersonic struct {
-	engine   *gin.Engine // gin 引擎
-	listener Listener    // 适配器
-	i18n     *I18n       // 国际化
+	engine     *gin.Engine      // gin 引擎
+	listener   Listener         // 适配器
+	i18n       *I18n            // 国际化
+	langConfig LangConfig       // 语言协商
+	isDev      bool             // 是否开发模式
+	openApi    *swagger.OpenApi // 文档
+	codes      *CodeRegistry    // 错误码注册表
+	status     statusRegistry   // 错误码 http 状态
+	problem    ProblemConfig    // RFC 7807 错误
+
+	tokenConfig   TokenConfig    // 令牌配置
+	tokenStorage  tokenStorage   // 令牌存储
+	tokenSessions *tokenSessions // 令牌会话
+
+	authorizer Authorizer // 授权者
+	tenantFunc TenantFunc // 租户解析
+
+	methods        map[string][]string  // 路径允许的方法
+	declared       map[string][]string  // 路径显式声明的方法
+	heads          map[string]headRoute // 待自动响应 HEAD 的路径
+	options        map[string]bool      // 已自动响应 OPTIONS 的路径
+	autoMutex      sync.Mutex           // 自动路由锁
+	autoRegistered atomic.Bool          // 是否已注册自动路由
+	serving        bool                 // 是否已开始服务，之后不能注册路由
+	cors           CorsConfig           // 跨域
+
+	maxBodySize int64                 // 最大请求体字节数
+	bodies      map[string]bodyOption // 路由请求体选项
+	storage     Storage               // 文件存储
+
+	hub       *hub                 // 推送中心
+	rateLimit *rateLimit           // 全局限流
+	telemetry *telemetry.Telemetry // 遥测
+
+	lifecycle *lifecycle // 生命周期
 }
 
 // New 创建
 func New(config Config) (*Hypersonic, error) {
-	// 创建引擎
+	// 创建令牌存储
+	storage, err := newTokenStorage(&config.Token, config.IsDev)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := config.Codes
+	if codes == nil {
+		codes = defaultCodeRegistry
+	}
+
+	// 授权须解析用户所属租户，不默认信任客户端首部
+	if config.Authorizer != nil && config.TenantFunc == nil {
+		return nil, errors.New("hypersonic tenant func required when authorizer configured")
+	}
+
+	// 校验跨域配置
+	if err = config.Cors.validate(); err != nil {
+		return nil, err
+	}
+
+	// 创建引擎，GetIp 与全局限流共用可信代理
 	engine := gin.New()
+	if err = engine.SetTrustedProxies(config.TrustedProxies); err != nil {
+		return nil, err
+	}
 
 	// 创建服务
 	hypersonic := &Hypersonic{
-		engine:   engine,
-		listener: config.Listener,
-		i18n:     config.I18n,
+		engine:     engine,
+		isDev:      config.IsDev,
+		listener:   config.Listener,
+		i18n:       config.I18n,
+		langConfig: config.Lang.normalize(),
+		openApi:    swagger.NewOpenApi("hypersonic", "1.0.0"),
+		codes:      codes,
+		status:     newStatusRegistry(codes),
+		problem:    config.Problem,
+
+		tokenConfig:  config.Token,
+		tokenStorage: storage,
+
+		authorizer: config.Authorizer,
+		tenantFunc: config.TenantFunc,
+
+		methods:  make(map[string][]string),
+		declared: make(map[string][]string),
+		heads:    make(map[string]headRoute),
+		options:  make(map[string]bool),
+		cors:     config.Cors,
+
+		maxBodySize: config.MaxBodySize,
+		bodies:      make(map[string]bodyOption),
+		storage:     config.Storage,
+
+		telemetry: config.Telemetry,
+	}
+
+	if hypersonic.maxBodySize == 0 {
+		hypersonic.maxBodySize = defaultMaxBodySize
+	}
+
+	// 创建推送中心
+	if hypersonic.hub, err = newHub(config.Hub); err != nil {
+		return nil, err
+	}
+
+	// 创建全局限流
+	if hypersonic.rateLimit, err = newRateLimit(hypersonic, config.RateLimit); err != nil {
+		return nil, err
 	}
 
+	// 创建生命周期，关闭时断开长连接
+	hypersonic.lifecycle = newLifecycle(config.Server, hypersonic)
+	hypersonic.lifecycle.server.RegisterOnShutdown(hypersonic.hub.close)
+
+	// 创建令牌会话
+	hypersonic.tokenSessions = newTokenSessions(&hypersonic.tokenConfig)
+
 	// 注册中间件
 	hypersonic.registerMiddleware(engine)
 
+	// 注册指标地址
+	if handler := hypersonic.telemetry.Handler(); handler != nil && config.MetricsPath != "" {
+		engine.GET(config.MetricsPath, gin.WrapH(handler))
+	}
+
 	if config.IsDev {
 		// 注册文档中间件
-		engine.GET("/doc/*any", swaggerMiddleware)
+		engine.GET("/doc/*any", hypersonic.swaggerMiddleware)
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
@@ -59,40 +190,50 @@ func New(config Config) (*Hypersonic, error) {
 }
 
 func (hypersonic *Hypersonic) registerMiddleware(engine *gin.Engine) {
+	// 注册链路中间件
+	engine.Use(traceMiddleware)
+
+	// 注册遥测中间件
+	if hypersonic.telemetry != nil {
+		engine.Use(hypersonic.telemetryMiddleware)
+	}
+
+	// 注册跨域中间件
+	if len(hypersonic.cors.AllowOrigins) > 0 {
+		engine.Use(hypersonic.cors.middleware)
+	}
+
 	// 注册耗时中件间
 	engine.Use(elapsedMiddleware)
 
-	// 注册 body 中间件
-	engine.Use(bodyMiddleware)
-
 	// 注册安全异常中间件
-	engine.Use(func(ctx *gin.Context) {
-		safeRecoverMiddleware(ctx)
-	})
+	engine.Use(hypersonic.safeRecoverMiddleware)
 
 	// 注册异常中间件
 	engine.Use(func(ctx *gin.Context) {
-		if err := recoverMiddleware(ctx); err != nil {
-			req := newRequest(ctx)
-			req.reply(nil, err, hypersonic.i18n, hypersonic.listener)
+		if req, err := hypersonic.recoverMiddleware(ctx); err != nil {
+			req.reply(nil, err)
 		}
 	})
 
+	// 注册全局限制中间件，在读取请求体前拒绝限流与禁止的请求
+	engine.Use(hypersonic.rateLimit.Filter)
+
+	// 注册 body 中间件，在异常中间件之后以便请求体超限时响应错误
+	engine.Use(hypersonic.bodyMiddleware)
+
 	// 注册 404 回调
 	engine.NoRoute(notFoundMiddleware)
 
 	// 注册 405 回调
 	engine.HandleMethodNotAllowed = true
 	engine.NoMethod(methodNotAllowedMiddleware)
-
-	// 注册全局限制中间件
-	engine.Use(newRateLimit(hypersonic.listener).Filter)
 }
 
 // SetMiddleware 设置中间件
 func (hypersonic *Hypersonic) SetMiddleware(requestMiddleware RequestMiddleware) {
 	hypersonic.engine.Use(func(ctx *gin.Context) {
-		requestMiddleware(newRequest(ctx))
+		requestMiddleware(newRequest(ctx, hypersonic))
 	})
 }
 
@@ -101,39 +242,64 @@ func (hypersonic *Hypersonic) RegisterControllers(basePath string, controllers [
 	for _, controller := range controllers {
 		group := hypersonic.engine.Group(basePath + controller.Path)
 
+		// 请求日志配置
+		if controller.Log != nil {
+			logConfig := controller.Log.normalize()
+
+			group.Use(func(ctx *gin.Context) {
+				ctx.Set(logTag, &logConfig)
+			})
+		}
+
+		// 校验授权
+		if len(controller.Roles) > 0 || len(controller.Permissions) > 0 {
+			hypersonic.mustAuthorizer()
+
+			group.Use(func(ctx *gin.Context) {
+				newRequest(ctx, hypersonic).authorize(controller.Roles, controller.Permissions)
+			})
+		}
+
 		// 启用中间件
 		if controller.RequestMiddleware != nil {
 			group.Use(func(ctx *gin.Context) {
-				controller.RequestMiddleware(newRequest(ctx))
+				controller.RequestMiddleware(newRequest(ctx, hypersonic))
 			})
 		}
 
 		// 路由函数
 		routerFunc := func(router Router) gin.HandlerFunc {
 			return func(ctx *gin.Context) {
-				router.run(ctx, hypersonic.i18n, hypersonic.listener)
+				router.run(ctx, hypersonic)
 			}
 		}
 
 		// 注册路由处理回调
+		routers := make([]Router, 0, len(controller.Routers))
 		for _, router := range controller.Routers {
-			switch router.HttpMethod {
-			case http.MethodGet:
-				group.GET(router.RelativePath, routerFunc(router))
-			case http.MethodPost:
-				group.POST(router.RelativePath, routerFunc(router))
-			case http.MethodPut:
-				group.PUT(router.RelativePath, routerFunc(router))
-			case http.MethodDelete:
-				group.DELETE(router.RelativePath, routerFunc(router))
-			default:
-				panic("hypersonic method not supported")
+			router = router.normalize()
+			routers = append(routers, router)
+
+			if len(router.Roles) > 0 || len(router.Permissions) > 0 {
+				hypersonic.mustAuthorizer()
 			}
+
+			hypersonic.addDoc(basePath, controller, router)
 		}
+
+		hypersonic.handle(group, routers, routerFunc)
+	}
+}
+
+// 声明角色或权限时必须配置授权者
+func (hypersonic *Hypersonic) mustAuthorizer() {
+	if hypersonic.authorizer == nil {
+		panic("hypersonic authorizer not configured")
 	}
 }
 
-// Run 启动服务
-func (hypersonic *Hypersonic) Run(port int) error {
-	return hypersonic.engine.Run(":" + strconv.Itoa(port))
+// ServeHTTP 实现 http.Handler，首次请求前注册自动路由，之后不能注册路由
+func (hypersonic *Hypersonic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	hypersonic.registerAutoRoutes()
+	hypersonic.engine.ServeHTTP(w, r)
 }
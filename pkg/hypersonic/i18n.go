@@ -7,81 +7,327 @@
 package hypersonic
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/pelletier/go-toml/v2"
 	"io/fs"
+	"log/slog"
 	"os"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
-// I18n 国际化
+// 字典，语言 -> 键 -> 文本，嵌套表以 . 连接，如 validate.required
+type i18nDict map[string]map[string]string
+
+// 已加载的字典与按名称排序的语言，重新加载时整体替换
+type i18nData struct {
+	dict  i18nDict // 字典
+	langs []Lang   // 语言
+}
+
+// 创建，缓存排序后的语言，避免每次协商时排序
+func newI18nData(dict i18nDict) *i18nData {
+	langs := make([]Lang, 0, len(dict))
+	for lang := range dict {
+		langs = append(langs, Lang(lang))
+	}
+
+	sort.Slice(langs, func(i, j int) bool {
+		return langs[i] < langs[j]
+	})
+
+	return &i18nData{dict: dict, langs: langs}
+}
+
+// I18n 国际化，查找只读字典，重新加载时整体替换
 type I18n struct {
-	dict map[string]map[string]any // 字典
+	fsys fs.FS                    // 文件系统
+	data atomic.Pointer[i18nData] // 字典与语言
 }
 
-// NewI18n 创建国际化
+// NewI18n 从目录创建国际化
 func NewI18n(dir string) (*I18n, error) {
-	i18n := I18n{
-		dict: make(map[string]map[string]any),
+	return NewI18nFS(os.DirFS(dir))
+}
+
+// NewI18nFS 从文件系统创建国际化，如 go:embed 目录以 fs.Sub 取子目录，文件名为语言，如 zh-CN.toml，
+// 子目录中同名文件合并到同一语言
+func NewI18nFS(fsys fs.FS) (*I18n, error) {
+	dict, err := loadI18nDict(fsys)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := fs.WalkDir(os.DirFS(dir), ".", func(path string, d fs.DirEntry, err error) error {
+	i18n := &I18n{
+		fsys: fsys,
+	}
+	i18n.data.Store(newI18nData(dict))
+
+	return i18n, nil
+}
+
+// 加载字典
+func loadI18nDict(fsys fs.FS) (i18nDict, error) {
+	dict := make(i18nDict)
+
+	if err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		if d.IsDir() {
+		if d.IsDir() || path.Ext(name) != ".toml" {
 			return nil
 		}
 
-		var pathByte []byte
-		if pathByte, err = os.ReadFile(dir + "/" + path); err != nil {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
 			return err
-		} else {
-			var t any
-			if err = toml.Unmarshal(pathByte, &t); err != nil {
+		}
+
+		var t map[string]any
+		if err = toml.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("i18n %s: %w", name, err)
+		}
+
+		lang := strings.TrimSuffix(path.Base(name), ".toml")
+		if dict[lang] == nil {
+			dict[lang] = make(map[string]string)
+		}
+
+		return flattenI18n("", t, dict[lang], name)
+	}); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+// 嵌套表展开为 . 连接的键
+func flattenI18n(prefix string, values map[string]any, dst map[string]string, name string) error {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if table, ok := value.(map[string]any); ok {
+			if err := flattenI18n(key, table, dst, name); err != nil {
 				return err
-			} else {
-				i18n.dict[path] = t.(map[string]any)
 			}
+
+			continue
+		}
+
+		if _, ok := dst[key]; ok {
+			return fmt.Errorf("i18n %s: duplicate key %s", name, key)
+		}
+
+		if s, ok := value.(string); ok {
+			dst[key] = s
+		} else {
+			dst[key] = fmt.Sprint(value)
+		}
+	}
+
+	return nil
+}
+
+// Reload 重新加载，失败时保留原字典
+func (i18n *I18n) Reload() error {
+	dict, err := loadI18nDict(i18n.fsys)
+	if err != nil {
+		return err
+	}
+
+	i18n.data.Store(newI18nData(dict))
+	return nil
+}
+
+// Watch 按间隔检查文件修改时间，变化时重新加载，用于开发模式，阻塞至 ctx 结束，如 go i18n.Watch(ctx, time.Second)
+func (i18n *I18n) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := i18n.modified()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if modified := i18n.modified(); modified != last {
+				last = modified
+
+				if err := i18n.Reload(); err != nil {
+					slog.Error("i18n reload failed", "err", err)
+				}
+			}
+		}
+	}
+}
+
+// 文件修改标识，由所有 toml 的路径、大小与修改时间组成
+func (i18n *I18n) modified() string {
+	builder := strings.Builder{}
+
+	_ = fs.WalkDir(i18n.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".toml" {
+			return nil
+		}
+
+		if info, err := d.Info(); err == nil {
+			_, _ = fmt.Fprintf(&builder, "%s:%d:%d;", name, info.Size(), info.ModTime().UnixNano())
 		}
 
 		return nil
-	}); err != nil {
-		return nil, err
+	})
+
+	return builder.String()
+}
+
+// Langs 已加载的语言，按名称排序
+func (i18n *I18n) Langs() []Lang {
+	return slices.Clone(i18n.langs())
+}
+
+// 已加载的语言，共享缓存，调用方不可修改
+func (i18n *I18n) langs() []Lang {
+	return i18n.data.Load().langs
+}
+
+// Missing 各语言缺少的键，键为其它语言已有的键，复数表仅要求 other，无缺少时为空，
+// 任一语言含 other 的表为复数表，如 step.one 所在表无 other 时按普通键检查
+func (i18n *I18n) Missing() map[Lang][]string {
+	dict := i18n.data.Load().dict
+
+	all := make(map[string]bool)
+	for _, values := range dict {
+		for key := range values {
+			all[key] = true
+		}
+	}
+
+	keys := make(map[string]bool)
+	for key := range all {
+		if i := strings.LastIndex(key, "."); i < 0 || !isPluralCategory(key[i+1:]) || !all[key[:i+1]+string(PluralOther)] {
+			keys[key] = true
+		}
 	}
 
-	return &i18n, nil
+	missing := make(map[Lang][]string)
+	for lang, values := range dict {
+		for key := range keys {
+			if _, ok := values[key]; !ok {
+				missing[Lang(lang)] = append(missing[Lang(lang)], key)
+			}
+		}
+
+		sort.Strings(missing[Lang(lang)])
+	}
+
+	for lang, values := range missing {
+		if len(values) == 0 {
+			delete(missing, lang)
+		}
+	}
+
+	return missing
 }
 
-// 翻译
-func (i18n I18n) t(lang string, key string) (string, bool) {
-	errMsg := fmt.Sprintf("Translation key '%s' for language '%s' not found.", key, lang)
+// Check 检查各语言的键是否一致，用于启动时，如 h.OnStart("i18n", func(context.Context) error { return i18n.Check() })
+func (i18n *I18n) Check() error {
+	missing := i18n.Missing()
 
-	v := i18n.dict[lang+".toml"]
-	if v == nil {
-		return errMsg, false
+	var errs []error
+	for _, lang := range i18n.Langs() {
+		if keys := missing[lang]; len(keys) > 0 {
+			errs = append(errs, fmt.Errorf("i18n %s missing keys: %s", lang, strings.Join(keys, ", ")))
+		}
 	}
 
-	value, ok := v[key].(string)
+	return errors.Join(errs...)
+}
+
+// 翻译，不存在时返回提示，不修改字典
+func (i18n *I18n) t(lang string, key string) (string, bool) {
+	value, ok := i18n.data.Load().dict[lang][key]
 	if !ok || value == "" {
-		v[key] = errMsg
-		return v[key].(string), false
+		return fmt.Sprintf("Translation key '%s' for language '%s' not found.", key, lang), false
 	}
 
 	return value, true
 }
 
-// T 翻译
-func (i18n I18n) T(lang string, key string) string {
+// 获取翻译表，如 validate 表中的 required，不存在时为空
+func (i18n *I18n) table(lang string, name string) map[string]string {
+	table := make(map[string]string)
+
+	prefix := name + "."
+	for key, value := range i18n.data.Load().dict[lang] {
+		if strings.HasPrefix(key, prefix) {
+			table[key[len(prefix):]] = value
+		}
+	}
+
+	return table
+}
+
+// T 翻译，嵌套表以 . 连接，如 user.login.title
+func (i18n *I18n) T(lang string, key string) string {
 	value, _ := i18n.t(lang, key)
 	return value
 }
 
 // Tf 翻译并格式化
-func (i18n I18n) Tf(lang string, key string, args ...any) string {
+func (i18n *I18n) Tf(lang string, key string, args ...any) string {
 	value, found := i18n.t(lang, key)
 	if found {
 		return fmt.Sprintf(value, args...)
 	}
 	return value
 }
+
+// Tn 翻译并替换命名占位符，如 "你好 {name}"
+func (i18n *I18n) Tn(lang string, key string, params M) string {
+	value, found := i18n.t(lang, key)
+	if found {
+		return replacePlaceholder(value, params)
+	}
+	return value
+}
+
+// Tp 按 CLDR 复数规则翻译，key 为表，如 [apple] one = "{count} apple"、other = "{count} apples"，
+// 缺少对应类别时使用 other，{count} 为数量
+func (i18n *I18n) Tp(lang string, key string, count int, params M) string {
+	named := M{"count": count}
+	for name, value := range params {
+		named[name] = value
+	}
+
+	for _, category := range []PluralCategory{pluralCategory(lang, count), PluralOther} {
+		if value, found := i18n.t(lang, key+"."+string(category)); found {
+			return replacePlaceholder(value, named)
+		}
+	}
+
+	return i18n.Tn(lang, key, named)
+}
+
+// 替换命名占位符，未提供的保留原样
+func replacePlaceholder(value string, params M) string {
+	if len(params) == 0 || !strings.Contains(value, "{") {
+		return value
+	}
+
+	pairs := make([]string, 0, len(params)*2)
+	for name, param := range params {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(param))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(value)
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-12 09:35:48
+ */
+
+package hypersonic
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// LangConfig 语言协商配置，候选为国际化已加载的语言
+type LangConfig struct {
+	Default     Lang                    // 默认语言，默认 en
+	Fallback    map[Lang][]Lang         // 回退链，如 zh-TW: [zh-CN]，均不可用时使用默认语言
+	Query       string                  // 覆盖语言的 query 参数，默认 lang，为 - 时不启用
+	Cookie      string                  // 覆盖语言的 cookie，默认 lang，为 - 时不启用
+	ProfileFunc func(req *Request) Lang // 用户偏好语言，如读取用户资料，无偏好时返回空
+}
+
+// 默认值
+func (config LangConfig) normalize() LangConfig {
+	if config.Default == "" {
+		config.Default = LangEn
+	}
+
+	if config.Query == "" {
+		config.Query = "lang"
+	}
+
+	if config.Cookie == "" {
+		config.Cookie = "lang"
+	}
+
+	return config
+}
+
+const langTag = "LANG" // 语言 tag
+
+// 带权重的值，如 Accept 的媒体范围、Accept-Language 的语言范围
+type qualityRange struct {
+	value string  // 值，如 zh-CN、application/json、*
+	q     float64 // 权重，0 为不可接受
+}
+
+// 解析 Accept、Accept-Language 等带权重的首部，按权重降序，保留权重为 0 的值以便排除
+func parseQuality(header string) []qualityRange {
+	ranges := make([]qualityRange, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if key, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(key) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil && f >= 0 && f <= 1 {
+					q = f
+				} else {
+					q = 0
+				}
+			}
+		}
+
+		ranges = append(ranges, qualityRange{value: value, q: q})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].q > ranges[j].q
+	})
+
+	return ranges
+}
+
+// 按 RFC 4647 匹配已加载的语言，依次尝试完全匹配与回退链、截断子标签后的完全匹配与回退链、前缀匹配，
+// 跳过 excluded 排除的语言
+func (config LangConfig) match(tag string, langs []Lang, excluded []string) (Lang, bool) {
+	find := func(tag string) (Lang, bool) {
+		for _, lang := range langs {
+			if strings.EqualFold(string(lang), tag) && !isExcludedLang(lang, excluded) {
+				return lang, true
+			}
+		}
+
+		return "", false
+	}
+
+	// 任意语言，优先默认语言
+	if tag == "*" {
+		if lang, ok := find(string(config.Default)); ok {
+			return lang, true
+		}
+
+		for _, lang := range langs {
+			if !isExcludedLang(lang, excluded) {
+				return lang, true
+			}
+		}
+
+		return "", false
+	}
+
+	// 逐级截断子标签，如 zh-Hant-TW -> zh-Hant -> zh
+	for {
+		if lang, ok := find(tag); ok {
+			return lang, true
+		}
+
+		for key, fallbacks := range config.Fallback {
+			if strings.EqualFold(string(key), tag) {
+				for _, fallback := range fallbacks {
+					if lang, ok := find(string(fallback)); ok {
+						return lang, true
+					}
+				}
+			}
+		}
+
+		i := strings.LastIndex(tag, "-")
+		if i <= 0 {
+			break
+		}
+		tag = tag[:i]
+
+		// 去掉末尾的单字符子标签，如 x
+		if j := strings.LastIndex(tag, "-"); j > 0 && j == len(tag)-2 {
+			tag = tag[:j]
+		}
+	}
+
+	// 前缀匹配，如 zh 匹配 zh-CN
+	for _, lang := range langs {
+		if hasLangPrefix(lang, tag) && !isExcludedLang(lang, excluded) {
+			return lang, true
+		}
+	}
+
+	return "", false
+}
+
+// 是否以语言范围为前缀，如 zh-CN 以 zh 为前缀
+func hasLangPrefix(lang Lang, tag string) bool {
+	return len(lang) > len(tag) && strings.EqualFold(string(lang[:len(tag)]), tag) && lang[len(tag)] == '-'
+}
+
+// 是否被权重为 0 的语言范围排除，如 zh;q=0 排除 zh-CN
+func isExcludedLang(lang Lang, excluded []string) bool {
+	for _, tag := range excluded {
+		if strings.EqualFold(string(lang), tag) || hasLangPrefix(lang, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 协商语言，优先级为 query、cookie、用户偏好、Accept-Language
+func (hypersonic *Hypersonic) negotiateLang(req *Request) Lang {
+	config := hypersonic.langConfig
+	langs := hypersonic.i18n.langs()
+
+	overrides := make([]string, 0, 3)
+	if config.Query != "-" {
+		overrides = append(overrides, req.ctx.Query(config.Query))
+	}
+
+	if config.Cookie != "-" {
+		if cookie := req.GetCookie(config.Cookie); cookie != nil {
+			overrides = append(overrides, *cookie)
+		}
+	}
+
+	if config.ProfileFunc != nil {
+		overrides = append(overrides, string(config.ProfileFunc(req)))
+	}
+
+	for _, override := range overrides {
+		if override = strings.TrimSpace(override); override != "" {
+			if lang, ok := config.match(override, langs, nil); ok {
+				return lang
+			}
+		}
+	}
+
+	// 权重为 0 的语言不可接受，匹配时跳过
+	ranges := parseQuality(req.ctx.GetHeader("Accept-Language"))
+
+	excluded := make([]string, 0)
+	for _, r := range ranges {
+		if r.q == 0 {
+			excluded = append(excluded, r.value)
+		}
+	}
+
+	for _, r := range ranges {
+		if r.q == 0 {
+			continue
+		}
+
+		if lang, ok := config.match(r.value, langs, excluded); ok {
+			return lang
+		}
+	}
+
+	return config.Default
+}
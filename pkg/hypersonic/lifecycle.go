@@ -0,0 +1,180 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-22 20:16:43
+ */
+
+package hypersonic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ServerConfig 服务配置
+type ServerConfig struct {
+	ReadTimeout       time.Duration // 读取超时
+	ReadHeaderTimeout time.Duration // 读取首部超时，默认 10 秒
+	WriteTimeout      time.Duration // 写入超时
+	IdleTimeout       time.Duration // 空闲超时
+	ShutdownTimeout   time.Duration // 收到信号后等待请求处理完成的超时，停止回调另有同样期限，默认 30 秒
+}
+
+// HookFunc 生命周期回调
+type HookFunc func(ctx context.Context) error
+
+// 生命周期回调
+type hook struct {
+	name     string   // 名称
+	hookFunc HookFunc // 回调
+	stops    int      // 注册启动回调时已注册的停止回调数
+}
+
+// 生命周期
+type lifecycle struct {
+	config       ServerConfig  // 配置
+	server       *http.Server  // 服务
+	startHooks   []hook        // 启动回调
+	stopHooks    []hook        // 停止回调
+	shutdownOnce *sync.Once    // 关闭一次
+	stopped      chan struct{} // 已关闭
+	shutdownErr  error         // 关闭错误
+}
+
+// 创建生命周期
+func newLifecycle(config ServerConfig, handler http.Handler) *lifecycle {
+	if config.ReadHeaderTimeout == 0 {
+		config.ReadHeaderTimeout = 10 * time.Second
+	}
+
+	if config.ShutdownTimeout == 0 {
+		config.ShutdownTimeout = 30 * time.Second
+	}
+
+	return &lifecycle{
+		config: config,
+		server: &http.Server{
+			Handler:           handler,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		},
+		shutdownOnce: &sync.Once{},
+		stopped:      make(chan struct{}),
+	}
+}
+
+// 按逆序执行停止回调
+func (lifecycle *lifecycle) stop(ctx context.Context, hooks []hook) error {
+	var errs []error
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].hookFunc(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("hypersonic stop %s: %w", hooks[i].name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// 以 ShutdownTimeout 为独立期限执行停止回调，不受已过期的关闭期限影响
+func (lifecycle *lifecycle) stopWithTimeout(ctx context.Context, hooks []hook) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), lifecycle.config.ShutdownTimeout)
+	defer cancel()
+
+	return lifecycle.stop(ctx, hooks)
+}
+
+// OnStart 注册启动回调，Run 监听端口前按注册顺序执行，
+// 失败时逆序执行在其之前注册的停止回调，因此应先注册资源的 OnStart 再注册其 OnStop
+func (hypersonic *Hypersonic) OnStart(name string, hookFunc HookFunc) {
+	hypersonic.lifecycle.startHooks = append(hypersonic.lifecycle.startHooks, hook{
+		name:     name,
+		hookFunc: hookFunc,
+		stops:    len(hypersonic.lifecycle.stopHooks),
+	})
+}
+
+// OnStop 注册停止回调，请求处理完成后按注册逆序执行，如关闭 mysql、redis、amqp
+func (hypersonic *Hypersonic) OnStop(name string, hookFunc HookFunc) {
+	hypersonic.lifecycle.stopHooks = append(hypersonic.lifecycle.stopHooks, hook{
+		name:     name,
+		hookFunc: hookFunc,
+	})
+}
+
+// Run 启动服务，收到 SIGINT、SIGTERM 或调用 Shutdown 后等待请求处理完成再返回
+func (hypersonic *Hypersonic) Run(port int) error {
+	lifecycle := hypersonic.lifecycle
+
+	// 注册自动路由，开始服务后路由树只读
+	hypersonic.registerAutoRoutes()
+
+	// 启动回调，失败时停止已启动的回调
+	for _, hook := range lifecycle.startHooks {
+		if err := hook.hookFunc(context.Background()); err != nil {
+			return errors.Join(fmt.Errorf("hypersonic start %s: %w", hook.name, err),
+				lifecycle.stopWithTimeout(context.Background(), lifecycle.stopHooks[:hook.stops]))
+		}
+	}
+
+	listener, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return errors.Join(err, lifecycle.stopWithTimeout(context.Background(), lifecycle.stopHooks))
+	}
+
+	hypersonic.listener.OnStart(listener.Addr().String())
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- lifecycle.server.Serve(listener)
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err = <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return errors.Join(err, hypersonic.Shutdown(context.Background()))
+		}
+
+		// 调用了 Shutdown
+		<-lifecycle.stopped
+		return lifecycle.shutdownErr
+	case <-signals:
+		ctx, cancel := context.WithTimeout(context.Background(), lifecycle.config.ShutdownTimeout)
+		defer cancel()
+
+		return hypersonic.Shutdown(ctx)
+	}
+}
+
+// Shutdown 关闭服务，停止接收新连接，在 ctx 期限内等待请求处理完成，
+// 之后执行停止回调，停止回调另有 ShutdownTimeout 期限
+func (hypersonic *Hypersonic) Shutdown(ctx context.Context) error {
+	lifecycle := hypersonic.lifecycle
+
+	lifecycle.shutdownOnce.Do(func() {
+		lifecycle.shutdownErr = errors.Join(
+			lifecycle.server.Shutdown(ctx),
+			lifecycle.stopWithTimeout(ctx, lifecycle.stopHooks),
+		)
+		hypersonic.listener.OnStop(lifecycle.shutdownErr)
+		close(lifecycle.stopped)
+	})
+
+	<-lifecycle.stopped
+	return lifecycle.shutdownErr
+}
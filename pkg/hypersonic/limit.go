@@ -9,7 +9,10 @@ package hypersonic
 import (
 	"fmt"
 	"framework/pkg/redis"
+	"framework/pkg/utils"
+	"math"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -23,11 +26,91 @@ const (
 	LimitPhone  LimitType = "Phone"  // 手机号
 )
 
+// LimitAlgorithm 限制算法
+type LimitAlgorithm string
+
+const (
+	LimitFixedWindow   LimitAlgorithm = "FixedWindow"   // 固定窗口，间隔内最多 MaxTimes 次，超限调用同样计数
+	LimitSlidingWindow LimitAlgorithm = "SlidingWindow" // 滑动窗口日志，任意连续间隔内最多 MaxTimes 次
+	LimitTokenBucket   LimitAlgorithm = "TokenBucket"   // 令牌桶，容量 MaxTimes，每个间隔补满
+)
+
 // LimitConfig 限制配置
 type LimitConfig struct {
-	LimitType LimitType     // 限制类型
-	MaxTimes  int64         // 最大次数
-	Interval  time.Duration // 限制间隔
+	LimitType LimitType      // 限制类型
+	Algorithm LimitAlgorithm // 限制算法，默认固定窗口
+	MaxTimes  int64          // 最大次数
+	Interval  time.Duration  // 限制间隔
+	KeyFunc   LimitKeyFunc   // 限制键，用于 Router.Limits，LimitIp、LimitUserId 可省略
+
+	ErrorCodes []Code // 仅在路由返回这些错误码时计数，为空时每次调用计数，如登录仅统计密码错误
+	Code       Code   // 超限错误码，默认 CodeRateLimit
+}
+
+// LimitKeyFunc 限制键提取
+type LimitKeyFunc func(req *Request) string
+
+// LimitKeyIp 按 ip
+func LimitKeyIp() LimitKeyFunc {
+	return func(req *Request) string {
+		return req.GetIp()
+	}
+}
+
+// LimitKeyUserId 按访问地址与用户 id，未登录时返回 CodeNoAuth
+func LimitKeyUserId() LimitKeyFunc {
+	return func(req *Request) string {
+		return req.GetUri() + "_" + req.Token.MustGetUserId()
+	}
+}
+
+// LimitKeyParam 按路径参数，如 /order/:id 中的 id
+func LimitKeyParam(name string) LimitKeyFunc {
+	return func(req *Request) string {
+		return req.GetParam(name)
+	}
+}
+
+// LimitKeyHeader 按首部
+func LimitKeyHeader(name string) LimitKeyFunc {
+	return func(req *Request) string {
+		return req.ctx.GetHeader(name)
+	}
+}
+
+// LimitKeyField 按路由参数字段，name 为 json、form、uri、header 标签名或字段名，限制前绑定 Router.Parameter
+func LimitKeyField(name string) LimitKeyFunc {
+	return func(req *Request) string {
+		return req.getParameterField(name)
+	}
+}
+
+// LimitKeys 组合限制键，如 LimitKeys(LimitKeyIp(), LimitKeyField("phone"))
+func LimitKeys(keyFuncs ...LimitKeyFunc) LimitKeyFunc {
+	return func(req *Request) string {
+		keys := make([]string, 0, len(keyFuncs))
+		for _, keyFunc := range keyFuncs {
+			keys = append(keys, keyFunc(req))
+		}
+
+		return strings.Join(keys, ":")
+	}
+}
+
+// 获取限制键
+func (limit Limit) getKey(req *Request) string {
+	if limit.config.KeyFunc != nil {
+		return limit.config.KeyFunc(req)
+	}
+
+	switch limit.config.LimitType {
+	case LimitIp:
+		return LimitKeyIp()(req)
+	case LimitUserId:
+		return LimitKeyUserId()(req)
+	default:
+		panic(NewErrorWithMessage(CodeInternalError, fmt.Sprintf("limit %s requires key func", limit.config.LimitType)))
+	}
 }
 
 // Limit 限制
@@ -38,48 +121,203 @@ type Limit struct {
 
 // NewLimit 创建限制
 func NewLimit(redis *redis.Redis, config LimitConfig) Limit {
+	if config.Algorithm == "" {
+		config.Algorithm = LimitFixedWindow
+	}
+
+	if config.Code == "" {
+		config.Code = CodeRateLimit
+	}
+
+	if _, ok := limitScripts[config.Algorithm]; !ok {
+		panic("hypersonic limit algorithm not supported")
+	}
+
+	// 次数或间隔为 0 时令牌桶速率无效，间隔以毫秒传入脚本
+	if config.MaxTimes <= 0 || config.Interval < time.Millisecond {
+		panic("hypersonic limit max times must be positive and interval at least 1ms")
+	}
+
 	return Limit{
 		redis:  redis,
 		config: config,
 	}
 }
 
-// 获取格式化 Key
-func (limit Limit) getFormatKey(key string) string {
-	return fmt.Sprintf("Limit:%s:%s", limit.config.LimitType, key)
+// 限制脚本，参数为最大次数、间隔毫秒、消耗次数（0 时仅查询）、唯一成员，返回是否允许、剩余次数、重试等待毫秒、配额恢复毫秒
+var limitScripts = map[LimitAlgorithm]redis.Script{
+	LimitFixedWindow: redis.NewScript(`
+local max = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local times = tonumber(redis.call('GET', KEYS[1]) or '0')
+local ttl = redis.call('PTTL', KEYS[1])
+
+-- 没有过期时间的键视为新窗口
+if ttl < 0 then
+	times = 0
+	ttl = interval
+end
+
+if cost > 0 then
+	if times == 0 then
+		redis.call('SET', KEYS[1], cost, 'PX', interval)
+		times = cost
+	else
+		times = redis.call('INCRBY', KEYS[1], cost)
+	end
+end
+
+local allowed = 0
+if (cost > 0 and times <= max) or (cost == 0 and times < max) then
+	allowed = 1
+end
+
+local left = math.max(max - times, 0)
+local retry = 0
+if left == 0 then
+	retry = ttl
+end
+
+local reset = 0
+if times > 0 then
+	reset = ttl
+end
+
+return {allowed, left, retry, reset}
+`),
+	LimitSlidingWindow: redis.NewScript(`
+local max = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - interval)
+local count = redis.call('ZCARD', KEYS[1])
+
+local allowed = 0
+if count + cost <= max and (cost > 0 or count < max) then
+	allowed = 1
+
+	for i = 1, cost do
+		redis.call('ZADD', KEYS[1], now, ARGV[4] .. ':' .. i)
+	end
+	count = count + cost
+
+	if cost > 0 then
+		redis.call('PEXPIRE', KEYS[1], interval)
+	end
+end
+
+local left = math.max(max - count, 0)
+local retry = 0
+if left == 0 then
+	local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+	retry = math.max(tonumber(oldest[2]) + interval - now, 0)
+end
+
+local reset = 0
+if count > 0 then
+	local newest = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+	reset = math.max(tonumber(newest[2]) + interval - now, 0)
+end
+
+return {allowed, left, retry, reset}
+`),
+	LimitTokenBucket: redis.NewScript(`
+local max = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+local cost = tonumber(ARGV[3])
+local rate = max / interval
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'timestamp')
+local tokens = tonumber(bucket[1]) or max
+local timestamp = tonumber(bucket[2]) or now
+tokens = math.min(max, tokens + math.max(now - timestamp, 0) * rate)
+
+local allowed = 0
+if cost > 0 then
+	if tokens >= cost then
+		tokens = tokens - cost
+		allowed = 1
+	end
+
+	redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'timestamp', now)
+	redis.call('PEXPIRE', KEYS[1], interval)
+elseif tokens >= 1 then
+	allowed = 1
+end
+
+local left = math.floor(tokens)
+local retry = 0
+if tokens < 1 then
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local reset = math.ceil((max - tokens) / rate)
+
+return {allowed, left, retry, reset}
+`),
 }
 
-// Add 增加
-func (limit Limit) Add(key string) bool {
-	formatKey := limit.getFormatKey(key)
-	exist := limit.redis.Exists(formatKey)
-	times := limit.redis.SetIncr(formatKey)
+// LimitInfo 限制信息
+type LimitInfo struct {
+	LimitType  LimitType     // 限制类型
+	Limit      int64         // 最大次数
+	Remaining  int64         // 剩余次数
+	Reset      time.Duration // 配额完全恢复的时间
+	RetryAfter time.Duration // 剩余次数为 0 时距离下次允许调用的时间
+}
 
-	if !exist {
-		limit.redis.SetTtl(formatKey, limit.config.Interval)
+// 获取格式化 Key，固定窗口保持原有格式
+func (limit Limit) getFormatKey(key string) string {
+	if limit.config.Algorithm == LimitFixedWindow {
+		return fmt.Sprintf("Limit:%s:%s", limit.config.LimitType, key)
 	}
 
-	if times > limit.config.MaxTimes {
-		return false
+	return fmt.Sprintf("Limit:%s:%s:%s", limit.config.Algorithm, limit.config.LimitType, key)
+}
+
+// 原子执行限制脚本，cost 为 0 时仅查询
+func (limit Limit) take(key string, cost int64) (bool, LimitInfo) {
+	values := limit.redis.RunScript(limitScripts[limit.config.Algorithm], []string{limit.getFormatKey(key)},
+		limit.config.MaxTimes, limit.config.Interval.Milliseconds(), cost, utils.NanoId(16))
+
+	return values[0] == 1, LimitInfo{
+		LimitType:  limit.config.LimitType,
+		Limit:      limit.config.MaxTimes,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		Reset:      time.Duration(values[3]) * time.Millisecond,
 	}
+}
 
-	return true
+// Add 增加
+func (limit Limit) Add(key string) bool {
+	allowed, _ := limit.take(key, 1)
+	return allowed
+}
+
+// Info 限制信息
+func (limit Limit) Info(key string) LimitInfo {
+	_, info := limit.take(key, 0)
+	return info
 }
 
 // Left 剩余次数
 func (limit Limit) Left(key string) int64 {
-	formatKey := limit.getFormatKey(key)
-
-	if timesByte, ok := limit.redis.Get(formatKey); ok {
-		times, _ := strconv.Atoi(string(timesByte))
-		if limit.config.MaxTimes-int64(times) < 0 {
-			return 0
-		}
+	return limit.Info(key).Remaining
+}
 
-		return limit.config.MaxTimes - int64(times)
-	} else {
-		return limit.config.MaxTimes
-	}
+// RetryAfter 剩余次数为 0 时距离下次允许调用的时间，否则为 0
+func (limit Limit) RetryAfter(key string) time.Duration {
+	return limit.Info(key).RetryAfter
 }
 
 // Reset 重置
@@ -88,3 +326,21 @@ func (limit Limit) Reset(key string) {
 
 	limit.redis.Del(formatKey)
 }
+
+// 向上取整秒数
+func ceilSeconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
+
+// 设置限制响应首部，被拒绝时附加 Retry-After
+func (req *Request) setLimitHeaders(info LimitInfo, rejected bool) {
+	header := req.ctx.Writer.Header()
+
+	header.Set("RateLimit-Limit", strconv.FormatInt(info.Limit, 10))
+	header.Set("RateLimit-Remaining", strconv.FormatInt(info.Remaining, 10))
+	header.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(info.Reset), 10))
+
+	if rejected {
+		header.Set("Retry-After", strconv.FormatInt(max(ceilSeconds(info.RetryAfter), 1), 10))
+	}
+}
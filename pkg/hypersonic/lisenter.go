@@ -8,13 +8,133 @@ package hypersonic
 
 import (
 	"fmt"
+	"github.com/gin-gonic/gin"
 	"log/slog"
 )
 
-// Listener 监听者
+// Listener 监听者，仅关心部分事件时可嵌入 NopListener
 type Listener interface {
-	OnLimit(limitType LimitType, req *Request)
-	OnLog(req *Request, data *Data, err *Error)
+	OnLimit(req *Request, info LimitInfo)              // 限制调用
+	OnLog(req *Request, data *Data, err *Error)        // 日志，每个请求响应后调用
+	OnPanic(req *Request, recovered any, stack []byte) // 异常，堆栈完整，响应前调用
+	OnAuthFailure(req *Request, err *Error)            // 鉴权失败，响应 CodeNoAuth 或 CodeForbidden 时调用
+	OnNotFound(req *Request)                           // 未找到，响应 CodeNotFound 时调用
+	OnStart(addr string)                               // 启动，监听端口后调用
+	OnStop(err error)                                  // 停止，停止回调执行完成后调用
+}
+
+const codeTag = "CODE" // 错误码 tag
+
+// 获取响应错误码
+func getCode(ctx *gin.Context) Code {
+	if code, ok := ctx.Get(codeTag); ok {
+		return code.(Code)
+	}
+
+	return CodeOK
+}
+
+// 通知日志并记录错误码
+func (hypersonic *Hypersonic) onLog(req *Request, data *Data, err *Error) {
+	if err != nil {
+		req.ctx.Set(codeTag, err.Code)
+
+		switch err.Code {
+		case CodeNoAuth, CodeForbidden:
+			hypersonic.listener.OnAuthFailure(req, err)
+		case CodeNotFound:
+			hypersonic.listener.OnNotFound(req)
+		}
+	}
+
+	hypersonic.listener.OnLog(req, data, err)
+}
+
+// 通知限制调用并记录指标
+func (hypersonic *Hypersonic) onLimit(req *Request, info LimitInfo) {
+	hypersonic.telemetry.AddRateLimitRejection(req.ctx.FullPath(), string(info.LimitType))
+	hypersonic.listener.OnLimit(req, info)
+}
+
+// NopListener 空监听者，嵌入后只需实现关心的事件
+type NopListener struct{}
+
+// OnLimit 限制调用
+func (NopListener) OnLimit(*Request, LimitInfo) {}
+
+// OnLog 日志
+func (NopListener) OnLog(*Request, *Data, *Error) {}
+
+// OnPanic 异常
+func (NopListener) OnPanic(*Request, any, []byte) {}
+
+// OnAuthFailure 鉴权失败
+func (NopListener) OnAuthFailure(*Request, *Error) {}
+
+// OnNotFound 未找到
+func (NopListener) OnNotFound(*Request) {}
+
+// OnStart 启动
+func (NopListener) OnStart(string) {}
+
+// OnStop 停止
+func (NopListener) OnStop(error) {}
+
+// MultiListener 组合监听者，按顺序通知
+type MultiListener []Listener
+
+// NewMultiListener 创建组合监听者
+func NewMultiListener(listeners ...Listener) MultiListener {
+	return listeners
+}
+
+// OnLimit 限制调用
+func (multiListener MultiListener) OnLimit(req *Request, info LimitInfo) {
+	for _, listener := range multiListener {
+		listener.OnLimit(req, info)
+	}
+}
+
+// OnLog 日志
+func (multiListener MultiListener) OnLog(req *Request, data *Data, err *Error) {
+	for _, listener := range multiListener {
+		listener.OnLog(req, data, err)
+	}
+}
+
+// OnPanic 异常
+func (multiListener MultiListener) OnPanic(req *Request, recovered any, stack []byte) {
+	for _, listener := range multiListener {
+		listener.OnPanic(req, recovered, stack)
+	}
+}
+
+// OnAuthFailure 鉴权失败
+func (multiListener MultiListener) OnAuthFailure(req *Request, err *Error) {
+	for _, listener := range multiListener {
+		listener.OnAuthFailure(req, err)
+	}
+}
+
+// OnNotFound 未找到
+func (multiListener MultiListener) OnNotFound(req *Request) {
+	for _, listener := range multiListener {
+		listener.OnNotFound(req)
+	}
+}
+
+// OnStart 启动
+func (multiListener MultiListener) OnStart(addr string) {
+	for _, listener := range multiListener {
+		listener.OnStart(addr)
+	}
+}
+
+// OnStop 停止
+func (multiListener MultiListener) OnStop(err error) {
+	for _, listener := range multiListener {
+		listener.OnStop(err)
+	}
 }
 
 // EchoListener 回显监听者
@@ -30,14 +150,15 @@ func NewEchoListener() EchoListener {
 }
 
 // OnLimit 限制调用
-func (echoListener EchoListener) OnLimit(limitType LimitType, req *Request) {
-	msg := fmt.Sprintf("OnLimit Ip => %s, Uri => %s", req.GetIp(), req.GetUri())
+func (echoListener EchoListener) OnLimit(req *Request, info LimitInfo) {
+	msg := fmt.Sprintf("OnLimit Ip => %s, Uri => %s, Limit => %d, RetryAfter => %s",
+		req.GetIp(), req.GetUri(), info.Limit, info.RetryAfter)
 
-	switch limitType {
-	case LimitIp:
-		slog.Error(msg)
+	switch info.LimitType {
 	case LimitUserId:
-		slog.Error(msg + fmt.Sprintf(", UserId => %s", req.Token.MustGetUserId()))
+		slog.Error(msg+fmt.Sprintf(", UserId => %s", req.Token.MustGetUserId()), "requestId", req.GetRequestId())
+	default:
+		slog.Error(msg, "requestId", req.GetRequestId())
 	}
 }
 
@@ -62,10 +183,42 @@ func (echoListener EchoListener) OnLog(req *Request, data *Data, err *Error) {
 	requestModel.Filed(req, req.Token.GetUserId(), &response, req.GetElapsed())
 
 	if data != nil {
-		slog.Debug(requestModel.String())
+		slog.Debug(requestModel.String(), "requestId", requestModel.RequestId)
+	}
+
+	if err != nil {
+		slog.Error(requestModel.String(), "requestId", requestModel.RequestId)
 	}
+}
+
+// OnPanic 异常
+func (echoListener EchoListener) OnPanic(req *Request, recovered any, stack []byte) {
+	slog.Error(fmt.Sprintf("OnPanic Uri => %s, Panic => %+v\n%s", req.GetUri(), recovered, stack),
+		"requestId", req.GetRequestId())
+}
+
+// OnAuthFailure 鉴权失败
+func (echoListener EchoListener) OnAuthFailure(req *Request, err *Error) {
+	slog.Warn(fmt.Sprintf("OnAuthFailure Ip => %s, Uri => %s, Code => %s", req.GetIp(), req.GetUri(), err.Code),
+		"requestId", req.GetRequestId())
+}
+
+// OnNotFound 未找到
+func (echoListener EchoListener) OnNotFound(req *Request) {
+	slog.Debug(fmt.Sprintf("OnNotFound Ip => %s, Uri => %s", req.GetIp(), req.GetUri()),
+		"requestId", req.GetRequestId())
+}
+
+// OnStart 启动
+func (echoListener EchoListener) OnStart(addr string) {
+	slog.Info("OnStart Addr => " + addr)
+}
 
+// OnStop 停止
+func (echoListener EchoListener) OnStop(err error) {
 	if err != nil {
-		slog.Error(requestModel.String())
+		slog.Error("OnStop Err => " + err.Error())
+	} else {
+		slog.Info("OnStop")
 	}
 }
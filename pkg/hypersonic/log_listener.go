@@ -0,0 +1,311 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-05 10:17:53
+ */
+
+package hypersonic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// 脱敏替换值
+const redacted = "***"
+
+// LogConfig 请求日志配置
+type LogConfig struct {
+	Handler       slog.Handler  // 处理器，默认 json 输出到标准输出，控制器未配置时使用监听者的处理器
+	RedactHeaders []string      // 脱敏首部，默认 Authorization、Cookie、X-Api-Key
+	RedactFields  []string      // 脱敏字段，默认 password，json 路径如 user.password 从根匹配，无 . 时匹配任意层级，亦用于表单
+	MaxBodySize   int           // 请求体与响应截断字节数，默认 4096，小于 0 时不记录
+	SampleRate    float64       // 成功请求采样率，默认 1 全部记录，小于 0 时不记录，错误与慢请求不采样
+	SlowThreshold time.Duration // 慢请求阈值，超过时以 Warn 记录，默认不启用
+}
+
+// 默认值
+func (config LogConfig) normalize() LogConfig {
+	if config.RedactHeaders == nil {
+		config.RedactHeaders = []string{"Authorization", "Cookie", "X-Api-Key"}
+	}
+
+	if config.RedactFields == nil {
+		config.RedactFields = []string{"password"}
+	}
+
+	if config.MaxBodySize == 0 {
+		config.MaxBodySize = 4096
+	}
+
+	if config.SampleRate == 0 {
+		config.SampleRate = 1
+	}
+
+	return config
+}
+
+const logTag = "LOG" // 控制器日志配置 tag
+
+// 获取控制器日志配置
+func getLogConfig(ctx *gin.Context) *LogConfig {
+	if config, ok := ctx.Get(logTag); ok {
+		return config.(*LogConfig)
+	}
+
+	return nil
+}
+
+// LogListener 结构化日志监听者，鉴权失败与未找到已由 OnLog 记录
+type LogListener struct {
+	NopListener
+
+	config LogConfig // 配置
+}
+
+// NewLogListener 创建结构化日志监听者
+func NewLogListener(config LogConfig) *LogListener {
+	config = config.normalize()
+
+	if config.Handler == nil {
+		config.Handler = slog.NewJSONHandler(os.Stdout, nil)
+	}
+
+	return &LogListener{
+		config: config,
+	}
+}
+
+// 获取请求所属控制器的配置
+func (logListener *LogListener) getConfig(req *Request) LogConfig {
+	config := getLogConfig(req.ctx)
+	if config == nil {
+		return logListener.config
+	}
+
+	if config.Handler == nil {
+		overwrite := *config
+		overwrite.Handler = logListener.config.Handler
+		return overwrite
+	}
+
+	return *config
+}
+
+// OnLimit 限制调用
+func (logListener *LogListener) OnLimit(req *Request, info LimitInfo) {
+	config := logListener.getConfig(req)
+
+	slog.New(config.Handler).LogAttrs(context.Background(), slog.LevelWarn, "limit",
+		slog.String("requestId", req.GetRequestId()),
+		slog.String("method", req.GetMethod()),
+		slog.String("uri", req.GetUri()),
+		slog.String("ip", req.GetIp()),
+		slog.String("limitType", string(info.LimitType)),
+		slog.Int64("limit", info.Limit),
+		slog.Duration("retryAfter", info.RetryAfter),
+	)
+}
+
+// OnLog 日志，成功请求按采样率记录，错误与慢请求总是记录
+func (logListener *LogListener) OnLog(req *Request, data *Data, err *Error) {
+	config := logListener.getConfig(req)
+
+	elapsed := time.Duration(req.GetElapsed()) * time.Millisecond
+	slow := config.SlowThreshold > 0 && elapsed >= config.SlowThreshold
+	status := req.ctx.Writer.Status()
+
+	level := slog.LevelInfo
+	switch {
+	case err != nil && req.hypersonic.status.get(err.Code) >= http.StatusInternalServerError:
+		level = slog.LevelError
+	case err != nil || slow:
+		level = slog.LevelWarn
+	case config.SampleRate < 0 || rand.Float64() >= config.SampleRate:
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("requestId", req.GetRequestId()),
+		slog.String("method", req.GetMethod()),
+		slog.String("uri", req.GetUri()),
+		slog.String("route", req.ctx.FullPath()),
+		slog.String("ip", req.GetIp()),
+		slog.Int("status", status),
+		slog.Int64("elapsed", elapsed.Milliseconds()),
+	}
+
+	if userId := req.Token.GetUserId(); userId != nil {
+		attrs = append(attrs, slog.String("userId", *userId))
+	}
+
+	if slow {
+		attrs = append(attrs, slog.Bool("slow", true))
+	}
+
+	attrs = append(attrs, slog.Any("header", redactHeader(req.GetAllHeader(), config.RedactHeaders)))
+
+	if config.MaxBodySize > 0 {
+		if body := req.GetBody(); body != nil {
+			attrs = append(attrs, slog.String("body",
+				truncate(RedactBody(*body, req.ctx.ContentType(), config.RedactFields), config.MaxBodySize)))
+		}
+
+		if data != nil {
+			attrs = append(attrs, slog.String("response",
+				truncate(RedactBody(data.String(), gin.MIMEJSON, config.RedactFields), config.MaxBodySize)))
+		}
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.String("code", string(err.Code)), slog.String("error", err.String()))
+	}
+
+	slog.New(config.Handler).LogAttrs(context.Background(), level, "request", attrs...)
+}
+
+// OnPanic 异常，记录完整堆栈
+func (logListener *LogListener) OnPanic(req *Request, recovered any, stack []byte) {
+	config := logListener.getConfig(req)
+
+	slog.New(config.Handler).LogAttrs(context.Background(), slog.LevelError, "panic",
+		slog.String("requestId", req.GetRequestId()),
+		slog.String("method", req.GetMethod()),
+		slog.String("uri", req.GetUri()),
+		slog.String("ip", req.GetIp()),
+		slog.String("panic", fmt.Sprintf("%+v", recovered)),
+		slog.String("stack", string(stack)),
+	)
+}
+
+// OnStart 启动
+func (logListener *LogListener) OnStart(addr string) {
+	slog.New(logListener.config.Handler).LogAttrs(context.Background(), slog.LevelInfo, "start",
+		slog.String("addr", addr))
+}
+
+// OnStop 停止
+func (logListener *LogListener) OnStop(err error) {
+	if err != nil {
+		slog.New(logListener.config.Handler).LogAttrs(context.Background(), slog.LevelError, "stop",
+			slog.String("error", err.Error()))
+	} else {
+		slog.New(logListener.config.Handler).LogAttrs(context.Background(), slog.LevelInfo, "stop")
+	}
+}
+
+// 首部脱敏，多个值以 , 连接
+func redactHeader(header http.Header, names []string) map[string]string {
+	redactedHeader := make(map[string]string, len(header))
+
+	for key, values := range header {
+		redactedHeader[key] = strings.Join(values, ", ")
+
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				redactedHeader[key] = redacted
+				break
+			}
+		}
+	}
+
+	return redactedHeader
+}
+
+// RedactBody 请求体按字段脱敏，支持 json 与表单，其它格式原样返回
+func RedactBody(body string, contentType string, fields []string) string {
+	if len(fields) == 0 {
+		return body
+	}
+
+	if strings.HasPrefix(contentType, gin.MIMEPOSTForm) {
+		values, err := url.ParseQuery(body)
+		if err != nil {
+			return body
+		}
+
+		for key := range values {
+			if matchField(key, "", fields) {
+				values[key] = []string{redacted}
+			}
+		}
+
+		return values.Encode()
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if decoder.Decode(&value) != nil {
+		return body
+	}
+
+	buffer := bytes.Buffer{}
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if encoder.Encode(redactValue(value, "", fields)) != nil {
+		return body
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// json 值脱敏，数组不计入路径
+func redactValue(value any, path string, fields []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+
+			if matchField(key, childPath, fields) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(child, childPath, fields)
+			}
+		}
+	case []any:
+		for i, child := range v {
+			v[i] = redactValue(child, path, fields)
+		}
+	}
+
+	return value
+}
+
+// 字段是否脱敏，忽略大小写
+func matchField(key string, path string, fields []string) bool {
+	for _, field := range fields {
+		if strings.Contains(field, ".") {
+			if strings.EqualFold(path, field) {
+				return true
+			}
+		} else if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 截断，不拆分多字节字符
+func truncate(value string, maxSize int) string {
+	if len(value) <= maxSize {
+		return value
+	}
+
+	return fmt.Sprintf("%s...(truncated %d bytes)", strings.ToValidUTF8(value[:maxSize], ""), len(value)-maxSize)
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-21 10:05:19
+ */
+
+package hypersonic
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+)
+
+// MethodAny 任意方法
+const MethodAny = "ANY"
+
+// 支持的方法
+var supportedMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodConnect,
+	http.MethodOptions,
+	http.MethodTrace,
+}
+
+// 拼接路径，与 gin 保持一致
+func joinPaths(absolutePath string, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+
+	return finalPath
+}
+
+// 记录路径允许的方法
+func (hypersonic *Hypersonic) addMethod(fullPath string, method string) {
+	methods := hypersonic.methods[fullPath]
+
+	if method == MethodAny {
+		methods = supportedMethods
+	} else if !slices.Contains(methods, method) {
+		methods = append(methods, method)
+	}
+
+	hypersonic.methods[fullPath] = methods
+}
+
+// 待自动响应 HEAD 的 GET 路由
+type headRoute struct {
+	group        *gin.RouterGroup // 路由组
+	relativePath string           // 相对路径
+	handler      gin.HandlerFunc  // 处理函数
+}
+
+// 注册路由，GET 自动响应 HEAD，每个路径自动响应 OPTIONS，自动路由在 Run 或首次请求前注册，
+// 开始服务后路由树只读，不能再注册路由
+func (hypersonic *Hypersonic) handle(group *gin.RouterGroup, routers []Router, handlerFunc func(router Router) gin.HandlerFunc) {
+	hypersonic.autoMutex.Lock()
+	defer hypersonic.autoMutex.Unlock()
+
+	if hypersonic.serving {
+		panic("hypersonic routes must be registered before serving")
+	}
+
+	for _, router := range routers {
+		fullPath := joinPaths(group.BasePath(), router.RelativePath)
+
+		switch {
+		case router.HttpMethod == MethodAny:
+			group.Any(router.RelativePath, handlerFunc(router))
+		case slices.Contains(supportedMethods, router.HttpMethod):
+			group.Handle(router.HttpMethod, router.RelativePath, handlerFunc(router))
+		default:
+			panic("hypersonic method not supported")
+		}
+
+		hypersonic.declared[fullPath] = append(hypersonic.declared[fullPath], router.HttpMethod)
+		hypersonic.addMethod(fullPath, router.HttpMethod)
+		hypersonic.addBody(fullPath, router)
+
+		// 自动响应 HEAD，长连接除外
+		if _, ok := hypersonic.heads[fullPath]; !ok && router.HttpMethod == http.MethodGet && router.InvokeFunc != nil {
+			hypersonic.heads[fullPath] = headRoute{
+				group:        group,
+				relativePath: router.RelativePath,
+				handler:      handlerFunc(router),
+			}
+		}
+	}
+}
+
+// 注册自动响应的 HEAD 与 OPTIONS 并开始服务，延迟到 Run 或首次请求前以便其它控制器对同一路径显式声明的方法优先，
+// 请求仅在注册完成后进入路由，之后不再修改路由树
+func (hypersonic *Hypersonic) registerAutoRoutes() {
+	if hypersonic.autoRegistered.Load() {
+		return
+	}
+
+	hypersonic.autoMutex.Lock()
+	defer hypersonic.autoMutex.Unlock()
+
+	if hypersonic.autoRegistered.Load() {
+		return
+	}
+	hypersonic.serving = true
+
+	for fullPath, methods := range hypersonic.declared {
+		if slices.Contains(methods, MethodAny) {
+			continue
+		}
+
+		// 自动响应 HEAD
+		if head, ok := hypersonic.heads[fullPath]; ok && !slices.Contains(methods, http.MethodHead) {
+			delete(hypersonic.heads, fullPath)
+			head.group.HEAD(head.relativePath, head.handler)
+			hypersonic.addMethod(fullPath, http.MethodHead)
+		}
+
+		// 自动响应 OPTIONS，不经过控制器中间件以便跨域预检
+		if !slices.Contains(methods, http.MethodOptions) && !hypersonic.options[fullPath] {
+			hypersonic.options[fullPath] = true
+			hypersonic.engine.OPTIONS(fullPath, hypersonic.optionsMiddleware(fullPath))
+			hypersonic.addMethod(fullPath, http.MethodOptions)
+		}
+	}
+
+	hypersonic.autoRegistered.Store(true)
+}
+
+// OPTIONS 中间件，跨域预检时响应允许的方法
+func (hypersonic *Hypersonic) optionsMiddleware(fullPath string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		methods := slices.Clone(hypersonic.methods[fullPath])
+		slices.Sort(methods)
+
+		ctx.Header("Allow", strings.Join(methods, ", "))
+		hypersonic.cors.preflight(ctx, methods)
+		ctx.AbortWithStatus(http.StatusNoContent)
+	}
+}
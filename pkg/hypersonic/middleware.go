@@ -8,16 +8,18 @@ package hypersonic
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
-	"framework/pkg/hypersonic/swagger"
-	"github.com/didip/tollbooth/v7"
-	"github.com/didip/tollbooth/v7/limiter"
+	"framework/pkg/telemetry"
+	"framework/pkg/trace"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"io"
+	"log/slog"
 	"net/http"
-	"path/filepath"
 	"runtime"
-	"strings"
+	"runtime/debug"
 	"time"
 )
 
@@ -27,22 +29,15 @@ type RequestMiddleware func(req *Request)
 const bodyTag = "BODY" // body tag
 
 // 设置 body
-func setBody(ctx *gin.Context) {
+func setBody(ctx *gin.Context, maxBodySize int64) {
 	if data, err := ctx.GetRawData(); err != nil {
-		panic(err.Error())
+		panic(bodyError(err, maxBodySize))
 	} else if len(data) != 0 {
 		ctx.Request.Body = io.NopCloser(bytes.NewBuffer(data))
 		ctx.Set(bodyTag, data)
 	}
 }
 
-// 内容中间件
-func bodyMiddleware(ctx *gin.Context) {
-	setBody(ctx)
-	ctx.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
-	ctx.Next()
-}
-
 // 获取 body
 func getBody(ctx *gin.Context) []byte {
 	if body, ok := ctx.Get(bodyTag); ok {
@@ -67,52 +62,71 @@ func getElapsed(ctx *gin.Context) int64 {
 	return elapsed.(int64)
 }
 
-// 405 中间件
-func methodNotAllowedMiddleware(ctx *gin.Context) {
-	ctx.Abort()
+const traceTag = "TRACE" // 链路 tag
 
-	panic(NewErrorWithArgv(CodeMethodNotAllowed, ctx.Request.URL.RequestURI(), ctx.Request.Method))
-}
+// 链路中间件，接受或生成请求 id 与 traceparent，附加到请求上下文并回显
+func traceMiddleware(ctx *gin.Context) {
+	requestTrace := trace.Parse(ctx.GetHeader(trace.RequestIdHeader), ctx.GetHeader(trace.TraceparentHeader))
 
-// 404 中间件
-func notFoundMiddleware(ctx *gin.Context) {
-	ctx.Abort()
+	ctx.Set(traceTag, requestTrace)
+	ctx.Request = ctx.Request.WithContext(trace.NewContext(ctx.Request.Context(), requestTrace))
 
-	panic(NewErrorWithArgv(CodeNotFound, ctx.Request.URL.RequestURI()))
+	ctx.Header(trace.RequestIdHeader, requestTrace.RequestId)
+	ctx.Header(trace.TraceparentHeader, requestTrace.Traceparent())
+	ctx.Next()
 }
 
-// 全局限流
-type rateLimit struct {
-	lmt      *limiter.Limiter // 对象
-	listener Listener         // 适配器
+// 获取链路
+func getTrace(ctx *gin.Context) trace.Trace {
+	if requestTrace, ok := ctx.Get(traceTag); ok {
+		return requestTrace.(trace.Trace)
+	}
+
+	return trace.Trace{}
 }
 
-// 创建全局限流
-func newRateLimit(adapter Listener) rateLimit {
-	const ttl int = 5 * 60              // 活跃时间（秒）
-	const capacity float64 = 5 * 60 * 5 // 容量
+// 遥测中间件，记录路由 span 与耗时指标，span 更新链路以便继续传递
+func (hypersonic *Hypersonic) telemetryMiddleware(ctx *gin.Context) {
+	start := time.Now()
+	method := ctx.Request.Method
+	route := ctx.FullPath()
 
-	lmt := tollbooth.NewLimiter(capacity, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Duration(ttl) * time.Second})
-	lmt.SetIPLookups([]string{"X-Forwarded-For", "X-Real-IP", "RemoteAddr"})
+	spanCtx, span := hypersonic.telemetry.StartSpan(ctx.Request.Context(), method+" "+route, oteltrace.SpanKindServer,
+		attribute.String("http.request.method", method), attribute.String("http.route", route))
 
-	return rateLimit{
-		lmt:      lmt,
-		listener: adapter,
+	ctx.Request = ctx.Request.WithContext(spanCtx)
+	if requestTrace, ok := trace.FromContext(spanCtx); ok {
+		ctx.Set(traceTag, requestTrace)
+		ctx.Header(trace.TraceparentHeader, requestTrace.Traceparent())
 	}
-}
 
-// Filter 限流中间件
-func (rateLimit rateLimit) Filter(ctx *gin.Context) {
-	if err := tollbooth.LimitByRequest(rateLimit.lmt, ctx.Writer, ctx.Request); err != nil {
-		ctx.Abort()
+	ctx.Next()
 
-		req := newRequest(ctx)
-		rateLimit.listener.OnLimit(LimitIp, req)
+	code := getCode(ctx)
+	status := ctx.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status), attribute.String("hypersonic.code", string(code)))
 
-		panic(NewErrorWithArgv(CodeRateLimit, req.GetUri(), req.GetIp()))
-	} else {
-		ctx.Next()
+	var err error
+	if status >= http.StatusInternalServerError {
+		err = errors.New(string(code))
 	}
+
+	telemetry.EndSpan(span, err)
+	hypersonic.telemetry.ObserveRequest(method, route, string(code), time.Since(start))
+}
+
+// 405 中间件
+func methodNotAllowedMiddleware(ctx *gin.Context) {
+	ctx.Abort()
+
+	panic(NewErrorWithArgv(CodeMethodNotAllowed, ctx.Request.URL.RequestURI(), ctx.Request.Method))
+}
+
+// 404 中间件
+func notFoundMiddleware(ctx *gin.Context) {
+	ctx.Abort()
+
+	panic(NewErrorWithArgv(CodeNotFound, ctx.Request.URL.RequestURI()))
 }
 
 // 获取堆栈
@@ -131,53 +145,56 @@ func getStack(skip int, level int) []string {
 }
 
 // 异常捕获中间件
-func recoverMiddleware(ctx *gin.Context) (err *Error) {
+func (hypersonic *Hypersonic) recoverMiddleware(ctx *gin.Context) (req *Request, err *Error) {
 	defer func() {
 		if recovered := recover(); recovered != nil {
-			if e, ok := recovered.(*Error); ok {
-				err = e
-			} else {
-				err = NewErrorWithArgv(CodeInternalError, fmt.Sprintf("%+v", recovered), getStack(0, 10))
-			}
+			req = newRequest(ctx, hypersonic)
+			err = hypersonic.recoveredError(req, recovered)
 		}
 	}()
 
 	ctx.Next()
 
-	return err
+	return req, err
+}
+
+// 异常转换为错误，非 *Error 异常以完整堆栈通知监听者，发布模式下异常与堆栈不响应给客户端
+func (hypersonic *Hypersonic) recoveredError(req *Request, recovered any) *Error {
+	if e, ok := recovered.(*Error); ok {
+		return e
+	}
+
+	hypersonic.listener.OnPanic(req, recovered, debug.Stack())
+
+	return hypersonic.panicError(recovered)
 }
 
-// 安全异常捕获中间件，用于在抛出异常时触发了一个异常。
-func safeRecoverMiddleware(ctx *gin.Context) {
+// 异常错误，开发模式下附加异常与堆栈，发布模式下不响应异常内容，仅通知监听者与写入日志
+func (hypersonic *Hypersonic) panicError(recovered any) *Error {
+	if hypersonic.isDev {
+		return NewErrorWithArgv(CodeInternalError, fmt.Sprintf("%+v", recovered), getStack(0, 10))
+	}
+
+	return NewError(CodeInternalError)
+}
+
+// 安全异常捕获中间件，用于在抛出异常时触发了一个异常。此时监听者可能已不可用，堆栈输出到日志
+func (hypersonic *Hypersonic) safeRecoverMiddleware(ctx *gin.Context) {
 	defer func() {
 		if recovered := recover(); recovered != nil {
-			if e, ok := recovered.(*Error); ok {
-				ctx.AbortWithStatusJSON(http.StatusBadRequest, *e)
-			} else {
-				e = NewErrorWithArgv(CodeInternalError, fmt.Sprintf("%+v", recovered), getStack(0, 10))
-				ctx.AbortWithStatusJSON(http.StatusBadRequest, *e)
+			e, ok := recovered.(*Error)
+			if !ok {
+				slog.Error(fmt.Sprintf("hypersonic safe recover %+v\n%s", recovered, debug.Stack()),
+					"requestId", getTrace(ctx).RequestId)
+				e = hypersonic.panicError(recovered)
 			}
+
+			reply := *e
+			reply.RequestId = getTrace(ctx).RequestId
+			ctx.Set(codeTag, e.Code)
+			newRequest(ctx, hypersonic).abortWithError(&reply)
 		}
 	}()
 
 	ctx.Next()
 }
-
-// 文档中间件
-func swaggerMiddleware(ctx *gin.Context) {
-	path := ctx.Request.URL.Path
-
-	// 非 /doc 开头不是文档
-	if !strings.HasPrefix(path, "/doc/") {
-		return
-	}
-
-	// 过滤掉非法的 /doc/? 路径
-	suffix := filepath.Base(path)
-	if suffix != "doc" && suffix != "doc.json" && suffix != "redoc.js" {
-		ctx.Redirect(http.StatusFound, "/doc")
-		return
-	}
-
-	_, _ = ctx.Writer.Write(swagger.Redoc(suffix))
-}
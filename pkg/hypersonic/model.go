@@ -6,7 +6,10 @@
 
 package hypersonic
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // Page 页
 type Page struct {
@@ -28,6 +31,7 @@ type M map[string]any
 type Data struct {
 	Data       any         `json:"data,omitempty"`       // 数据
 	Pagination *Pagination `json:"pagination,omitempty"` // 分页
+	response   response    // 非 json 响应，如文件、流、重定向
 }
 
 // NewData 创建数据
@@ -40,6 +44,10 @@ func NewData(data any, pagination *Pagination) *Data {
 
 // String 字符串
 func (data Data) String() string {
+	if data.response != nil {
+		return data.response.String()
+	}
+
 	dataBytes, _ := json.Marshal(data)
 	return string(dataBytes)
 }
@@ -61,6 +69,7 @@ const (
 	CodeRateLimit        Code = "RateLimit"        // 速率限制
 	CodeForbidden        Code = "Forbidden"        // 禁止访问
 	CodeNoAuth           Code = "NoAuth"           // 未授权
+	CodeRequestTooLarge  Code = "RequestTooLarge"  // 请求体过大
 )
 
 // Error 错误
@@ -68,6 +77,10 @@ type Error struct {
 	Code    Code   `json:"code,omitempty"`    // 错误码
 	Message string `json:"message,omitempty"` // 错误信息
 	Argv    []any  `json:"argv,omitempty"`    // 参数值
+
+	Errors map[string]string `json:"errors,omitempty"` // 字段错误，参数验证失败时填充
+
+	RequestId string `json:"requestId,omitempty"` // 请求 id，响应时填充
 }
 
 // NewError 创建错误
@@ -99,11 +112,16 @@ func (err *Error) String() string {
 	return string(errBytes)
 }
 
-// 国际化
-func (err *Error) i18n(lang string, i18n *I18n) {
-	if len(err.Argv) > 0 {
-		err.Message = i18n.Tf(lang, string(err.Code), err.Argv)
-	} else if err.Message == "" {
-		err.Message = i18n.T(lang, string(err.Code))
+// 国际化，缺少翻译时使用错误码注册的默认消息
+func (err *Error) i18n(lang string, i18n *I18n, codes *CodeRegistry) {
+	if len(err.Argv) == 0 && err.Message != "" {
+		return
+	}
+
+	value, found := codes.translate(i18n, lang, err.Code)
+	if found && len(err.Argv) > 0 {
+		value = fmt.Sprintf(value, err.Argv...)
 	}
+
+	err.Message = value
 }
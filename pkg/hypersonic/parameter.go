@@ -7,9 +7,11 @@
 package hypersonic
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/gookit/validate"
 	"github.com/gookit/validate/locales/zhcn"
+	"strings"
 	"sync"
 )
 
@@ -40,7 +42,47 @@ func (parameterPool ParameterPool[T]) Release(t T) {
 
 // https://github.com/gookit/validate/blob/master/README.zh-CN.md
 
-// 自定义验证器
+// 内置英文验证消息，注册中文前保存
+var enMessages = validate.CopyGlobalMessages()
+
+// 按请求语言验证参数，消息可由国际化 [validate] 表覆盖，如 required = "{field} 不能为空"
+func (req *Request) validate(param any) *Error {
+	lang := req.GetLang()
+
+	v := validate.Struct(param)
+	v.StopOnError = false
+
+	// 按基础语言选择，如 zh-TW 使用中文
+	if base, _, _ := strings.Cut(string(lang), "-"); strings.EqualFold(base, "zh") {
+		v.WithMessages(zhcn.Data)
+	} else {
+		v.WithMessages(enMessages)
+	}
+	v.WithMessages(req.hypersonic.i18n.table(string(lang), "validate"))
+
+	if v.Validate() {
+		return nil
+	}
+
+	return parameterError(v.Errors)
+}
+
+// 参数错误，验证失败时附加字段错误，键为 json 字段名
+func parameterError(err error) *Error {
+	e := NewErrorWithMessage(CodeParameterError, err.Error())
+
+	var validateErrors validate.Errors
+	if errors.As(err, &validateErrors) {
+		e.Errors = make(map[string]string, len(validateErrors))
+		for field := range validateErrors {
+			e.Errors[field] = validateErrors.FieldOne(field)
+		}
+	}
+
+	return e
+}
+
+// 自定义验证器，gin ShouldBind* 使用全局中文消息验证，Request 绑定不经过此验证器
 type customValidator struct{}
 
 // ValidateStruct 验证结构体
@@ -62,7 +104,7 @@ func (customValidator) Engine() any {
 
 // 初始化
 func init() {
-	// 中文认证
+	// 非请求验证，如 mysql.Repository.Save 使用中文
 	zhcn.RegisterGlobal()
 
 	// 更换验证器
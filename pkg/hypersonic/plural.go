@@ -0,0 +1,59 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-13 10:47:25
+ */
+
+package hypersonic
+
+import (
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+)
+
+// PluralCategory CLDR 复数类别
+type PluralCategory string
+
+const (
+	PluralZero  PluralCategory = "zero"  // 零
+	PluralOne   PluralCategory = "one"   // 单数
+	PluralTwo   PluralCategory = "two"   // 双数
+	PluralFew   PluralCategory = "few"   // 少数
+	PluralMany  PluralCategory = "many"  // 多数
+	PluralOther PluralCategory = "other" // 其它
+)
+
+// 是否为 other 以外的复数类别，各语言所需类别不同
+func isPluralCategory(name string) bool {
+	switch PluralCategory(name) {
+	case PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany:
+		return true
+	}
+
+	return false
+}
+
+// 复数形式对应的类别
+var pluralForms = map[plural.Form]PluralCategory{
+	plural.Zero:  PluralZero,
+	plural.One:   PluralOne,
+	plural.Two:   PluralTwo,
+	plural.Few:   PluralFew,
+	plural.Many:  PluralMany,
+	plural.Other: PluralOther,
+}
+
+// 获取整数的 CLDR 基数复数类别，规则来自 golang.org/x/text/feature/plural，无法解析的语言按英文规则
+// https://www.unicode.org/cldr/charts/latest/supplemental/language_plural_rules.html
+func pluralCategory(lang string, n int) PluralCategory {
+	tag, err := language.Parse(lang)
+	if err != nil {
+		tag = language.English
+	}
+
+	if n < 0 {
+		n = -n
+	}
+
+	return pluralForms[plural.Cardinal.MatchPlural(tag, n, 0, 0, 0, 0)]
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-08 10:12:34
+ */
+
+package hypersonic
+
+import (
+	"github.com/gin-gonic/gin"
+	"strings"
+)
+
+// MIMEProblemJSON RFC 7807 错误类型
+const MIMEProblemJSON = "application/problem+json"
+
+// ErrorFormat 错误响应格式
+type ErrorFormat string
+
+const (
+	ErrorFormatDefault   ErrorFormat = ""          // 默认，code、message、argv
+	ErrorFormatProblem   ErrorFormat = "problem"   // 总是以 RFC 7807 响应
+	ErrorFormatNegotiate ErrorFormat = "negotiate" // 请求 Accept 含 application/problem+json 时以 RFC 7807 响应
+)
+
+// ProblemConfig RFC 7807 错误配置
+type ProblemConfig struct {
+	Format   ErrorFormat // 格式，默认不启用
+	TypeBase string      // 问题类型地址前缀，type 为前缀加错误码，默认 urn:hypersonic:code:
+}
+
+// Problem RFC 7807 错误
+type Problem struct {
+	Type      string            `json:"type"`                // 问题类型，每个错误码一个地址
+	Title     string            `json:"title"`               // 标题，错误码翻译
+	Status    int               `json:"status"`              // http 状态
+	Detail    string            `json:"detail,omitempty"`    // 详情，错误信息
+	Instance  string            `json:"instance"`            // 实例，访问地址
+	Code      Code              `json:"code"`                // 错误码
+	RequestId string            `json:"requestId,omitempty"` // 请求 id
+	Errors    map[string]string `json:"errors,omitempty"`    // 字段错误，参数错误时填充
+}
+
+// 是否以 RFC 7807 响应
+func (config ProblemConfig) accept(ctx *gin.Context) bool {
+	switch config.Format {
+	case ErrorFormatProblem:
+		return true
+	case ErrorFormatNegotiate:
+		ranges := parseQuality(ctx.GetHeader("Accept"))
+
+		// 须显式接受 application/problem+json，且权重不低于 application/json
+		for _, r := range ranges {
+			if strings.EqualFold(r.value, MIMEProblemJSON) {
+				return r.q > 0 && r.q >= mediaQuality(ranges, gin.MIMEJSON)
+			}
+		}
+	}
+
+	return false
+}
+
+// 媒体类型的权重，依次匹配完全相同、type/* 与 */*，均不匹配时为 0
+func mediaQuality(ranges []qualityRange, mime string) float64 {
+	mainType, _, _ := strings.Cut(mime, "/")
+
+	for _, value := range []string{mime, mainType + "/*", "*/*"} {
+		for _, r := range ranges {
+			if strings.EqualFold(r.value, value) {
+				return r.q
+			}
+		}
+	}
+
+	return 0
+}
+
+// 响应错误，err 须已翻译并填充请求 id
+func (req *Request) abortWithError(err *Error) {
+	hypersonic := req.hypersonic
+	status := hypersonic.status.get(err.Code)
+
+	// 协商时响应格式随 Accept 变化
+	if hypersonic.problem.Format == ErrorFormatNegotiate {
+		req.ctx.Writer.Header().Add("Vary", "Accept")
+	}
+
+	if !hypersonic.problem.accept(req.ctx) {
+		req.ctx.AbortWithStatusJSON(status, err)
+		return
+	}
+
+	typeBase := hypersonic.problem.TypeBase
+	if typeBase == "" {
+		typeBase = "urn:hypersonic:code:"
+	}
+
+	title, found := hypersonic.codes.translate(hypersonic.i18n, string(req.GetLang()), err.Code)
+	if !found {
+		title = string(err.Code)
+	}
+
+	problem := Problem{
+		Type:      typeBase + string(err.Code),
+		Title:     title,
+		Status:    status,
+		Detail:    err.Message,
+		Instance:  req.ctx.Request.URL.RequestURI(),
+		Code:      err.Code,
+		RequestId: err.RequestId,
+		Errors:    err.Errors,
+	}
+
+	if problem.Detail == title {
+		problem.Detail = ""
+	}
+
+	// 先写入类型，gin 不会覆盖已有的 Content-Type
+	req.ctx.Header("Content-Type", MIMEProblemJSON)
+	req.ctx.AbortWithStatusJSON(status, problem)
+}
@@ -0,0 +1,217 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-31 09:46:12
+ */
+
+package hypersonic
+
+import (
+	"fmt"
+	"framework/pkg/redis"
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gin-gonic/gin"
+	"net"
+	"slices"
+	"strings"
+	"time"
+)
+
+// RateLimitKey 全局限流键
+type RateLimitKey string
+
+const (
+	RateLimitKeyIp     RateLimitKey = "Ip"     // 按 ip
+	RateLimitKeyUserId RateLimitKey = "UserId" // 按用户 id，未登录时按 ip
+)
+
+// RateLimitConfig 全局限流配置
+type RateLimitConfig struct {
+	Disabled bool         // 是否关闭
+	Rate     float64      // 每秒补充次数，默认 5
+	Burst    int64        // 最大突发次数，默认 1500
+	Key      RateLimitKey // 限流键，默认按 ip
+	KeyFunc  LimitKeyFunc // 自定义限流键，设置后替代 Key，执行时尚未读取请求体
+	Redis    *redis.Redis // 缓存，配置后多实例共享限流，否则在本实例内存中限流
+
+	AllowIps     []string // 不限流的 ip 或 CIDR，如办公室出口
+	DenyIps      []string // 禁止访问的 ip 或 CIDR
+	AllowUserIds []string // 不限流的用户 id
+	DenyUserIds  []string // 禁止访问的用户 id
+}
+
+// 全局限流
+type rateLimit struct {
+	config     RateLimitConfig  // 配置
+	lmt        *limiter.Limiter // 内存限流
+	limit      *Limit           // redis 限流
+	allowIps   []*net.IPNet     // 不限流的 ip
+	denyIps    []*net.IPNet     // 禁止访问的 ip
+	hypersonic *Hypersonic      // 服务
+}
+
+// 解析 ip 或 CIDR
+func parseIpNets(values []string) ([]*net.IPNet, error) {
+	ipNets := make([]*net.IPNet, 0, len(values))
+
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip == nil {
+				return nil, fmt.Errorf("invalid ip %s", value)
+			} else if ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+
+		ipNets = append(ipNets, ipNet)
+	}
+
+	return ipNets, nil
+}
+
+// 是否包含 ip
+func containsIp(ipNets []*net.IPNet, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(parsed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// 创建全局限流
+func newRateLimit(hypersonic *Hypersonic, config RateLimitConfig) (*rateLimit, error) {
+	if config.Rate == 0 {
+		config.Rate = 5
+	}
+
+	if config.Burst == 0 {
+		config.Burst = 5 * 60 * 5
+	}
+
+	if config.Key == "" {
+		config.Key = RateLimitKeyIp
+	}
+
+	rateLimit := &rateLimit{
+		config:     config,
+		hypersonic: hypersonic,
+	}
+
+	var err error
+	if rateLimit.allowIps, err = parseIpNets(config.AllowIps); err != nil {
+		return nil, err
+	}
+	if rateLimit.denyIps, err = parseIpNets(config.DenyIps); err != nil {
+		return nil, err
+	}
+
+	// 补满所需时间
+	interval := time.Duration(float64(config.Burst) / config.Rate * float64(time.Second))
+
+	if config.Redis != nil {
+		limit := NewLimit(config.Redis, LimitConfig{
+			LimitType: "Global",
+			Algorithm: LimitTokenBucket,
+			MaxTimes:  config.Burst,
+			Interval:  interval,
+		})
+		rateLimit.limit = &limit
+	} else {
+		rateLimit.lmt = tollbooth.NewLimiter(config.Rate, &limiter.ExpirableOptions{DefaultExpirationTTL: interval}).
+			SetBurst(int(config.Burst))
+	}
+
+	return rateLimit, nil
+}
+
+// 获取限流类型与键
+func (rateLimit *rateLimit) getKey(req *Request, userId *string) (LimitType, string) {
+	if rateLimit.config.KeyFunc != nil {
+		return LimitType("Custom"), rateLimit.config.KeyFunc(req)
+	}
+
+	if rateLimit.config.Key == RateLimitKeyUserId && userId != nil {
+		return LimitUserId, *userId
+	}
+
+	return LimitIp, req.GetIp()
+}
+
+// 限流，返回是否允许与限制信息
+func (rateLimit *rateLimit) take(limitType LimitType, key string) (bool, LimitInfo) {
+	if rateLimit.limit != nil {
+		allowed, info := rateLimit.limit.take(string(limitType)+":"+key, 1)
+		info.LimitType = limitType
+		return allowed, info
+	}
+
+	key = string(limitType) + ":" + key
+	reached := rateLimit.lmt.LimitReached(key)
+	tokens := int64(rateLimit.lmt.Tokens(key))
+
+	return !reached, LimitInfo{
+		LimitType:  limitType,
+		Limit:      rateLimit.config.Burst,
+		Remaining:  tokens,
+		Reset:      time.Duration(float64(rateLimit.config.Burst-tokens) / rateLimit.config.Rate * float64(time.Second)),
+		RetryAfter: time.Duration(float64(time.Second) / rateLimit.config.Rate),
+	}
+}
+
+// 是否需要解析用户 id
+func (rateLimit *rateLimit) needUserId() bool {
+	return rateLimit.config.Key == RateLimitKeyUserId ||
+		len(rateLimit.config.AllowUserIds) > 0 || len(rateLimit.config.DenyUserIds) > 0
+}
+
+// Filter 限流中间件
+func (rateLimit *rateLimit) Filter(ctx *gin.Context) {
+	req := newRequest(ctx, rateLimit.hypersonic)
+	ip := req.GetIp()
+
+	var userId *string
+	if rateLimit.needUserId() {
+		userId = req.Token.GetUserId()
+	}
+
+	// 禁止访问
+	if containsIp(rateLimit.denyIps, ip) || (userId != nil && slices.Contains(rateLimit.config.DenyUserIds, *userId)) {
+		ctx.Abort()
+		panic(NewErrorWithArgv(CodeForbidden, req.GetUri(), ip))
+	}
+
+	// 不限流
+	if rateLimit.config.Disabled || containsIp(rateLimit.allowIps, ip) ||
+		(userId != nil && slices.Contains(rateLimit.config.AllowUserIds, *userId)) {
+		ctx.Next()
+		return
+	}
+
+	allowed, info := rateLimit.take(rateLimit.getKey(req, userId))
+	if !allowed {
+		ctx.Abort()
+
+		req.setLimitHeaders(info, true)
+		rateLimit.hypersonic.onLimit(req, info)
+
+		panic(NewErrorWithArgv(CodeRateLimit, req.GetUri(), ip))
+	}
+
+	req.setLimitHeaders(info, false)
+	ctx.Next()
+}
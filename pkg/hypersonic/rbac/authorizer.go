@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-19 16:32:48
+ */
+
+package rbac
+
+import (
+	"fmt"
+	"framework/pkg/hypersonic"
+	"framework/pkg/mysql"
+	"framework/pkg/redis"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Authorizer 基于 mysql 角色权限表的授权者，授权缓存在 redis 中，用户在租户下无角色时无授权，可与 HeaderTenantFunc 配合
+type Authorizer struct {
+	repository repository    // 仓库
+	redis      *redis.Redis  // 缓存
+	ttl        time.Duration // 缓存时间
+}
+
+// New 创建授权者
+func New(mysql *mysql.Mysql, redis *redis.Redis, ttl time.Duration) *Authorizer {
+	return &Authorizer{
+		repository: newRepository(mysql),
+		redis:      redis,
+		ttl:        ttl,
+	}
+}
+
+// 获取租户版本 Key，角色权限变更时递增使租户下所有缓存失效
+func (authorizer *Authorizer) getVersionKey(tenantId string) string {
+	return fmt.Sprintf("Grants:Version:%s", tenantId)
+}
+
+// 获取授权缓存 Key
+func (authorizer *Authorizer) getGrantsKey(tenantId string, userId string) string {
+	var version int64
+	if b, ok := authorizer.redis.Get(authorizer.getVersionKey(tenantId)); ok {
+		version, _ = strconv.ParseInt(string(b), 10, 64)
+	}
+
+	return fmt.Sprintf("Grants:%s:%d:%s", tenantId, version, userId)
+}
+
+// GetGrants 获取用户在租户下的授权
+func (authorizer *Authorizer) GetGrants(tenantId string, userId string) hypersonic.Grants {
+	grantsKey := authorizer.getGrantsKey(tenantId, userId)
+
+	var grants hypersonic.Grants
+	if authorizer.redis.GetJson(grantsKey, &grants) {
+		return grants
+	}
+
+	grants.Roles = authorizer.repository.findRoles(tenantId, userId)
+	grants.Permissions = authorizer.repository.findPermissions(tenantId, grants.Roles)
+	authorizer.redis.SetJson(grantsKey, grants, authorizer.ttl)
+
+	return grants
+}
+
+// AddUserRole 添加用户角色
+func (authorizer *Authorizer) AddUserRole(tenantId string, userId string, role string) {
+	if slices.Contains(authorizer.repository.findRoles(tenantId, userId), role) {
+		return
+	}
+
+	authorizer.repository.userRole.Save(&UserRole{
+		ModelTenantId: mysql.ModelTenantId{TenantId: tenantId},
+		ModelUserId:   mysql.ModelUserId{UserId: userId},
+		Role:          role,
+	})
+
+	authorizer.redis.Del(authorizer.getGrantsKey(tenantId, userId))
+}
+
+// RemoveUserRole 移除用户角色
+func (authorizer *Authorizer) RemoveUserRole(tenantId string, userId string, role string) {
+	authorizer.repository.deleteUserRole(tenantId, userId, role)
+	authorizer.redis.Del(authorizer.getGrantsKey(tenantId, userId))
+}
+
+// AddRolePermission 添加角色权限
+func (authorizer *Authorizer) AddRolePermission(tenantId string, role string, permission string) {
+	if slices.Contains(authorizer.repository.findPermissions(tenantId, []string{role}), permission) {
+		return
+	}
+
+	authorizer.repository.rolePermission.Save(&RolePermission{
+		ModelTenantId: mysql.ModelTenantId{TenantId: tenantId},
+		Role:          role,
+		Permission:    permission,
+	})
+
+	authorizer.redis.SetIncr(authorizer.getVersionKey(tenantId))
+}
+
+// RemoveRolePermission 移除角色权限
+func (authorizer *Authorizer) RemoveRolePermission(tenantId string, role string, permission string) {
+	authorizer.repository.deleteRolePermission(tenantId, role, permission)
+	authorizer.redis.SetIncr(authorizer.getVersionKey(tenantId))
+}
+
+// 确保实现授权者接口
+var _ hypersonic.Authorizer = (*Authorizer)(nil)
@@ -0,0 +1,31 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-19 16:10:32
+ */
+
+package rbac
+
+import "framework/pkg/mysql"
+
+// UserRole 用户角色
+type UserRole struct {
+	mysql.ModelId
+	mysql.ModelTenantId
+	mysql.ModelUserId
+
+	Role string `json:"role" gorm:"type:varchar(64);not null;index;comment:角色" validate:"required|max_len:64"` // 角色
+
+	mysql.ModelTime
+}
+
+// RolePermission 角色权限
+type RolePermission struct {
+	mysql.ModelId
+	mysql.ModelTenantId
+
+	Role       string `json:"role" gorm:"type:varchar(64);not null;index;comment:角色" validate:"required|max_len:64"`   // 角色
+	Permission string `json:"permission" gorm:"type:varchar(128);not null;comment:权限" validate:"required|max_len:128"` // 权限
+
+	mysql.ModelTime
+}
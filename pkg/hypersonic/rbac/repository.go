@@ -0,0 +1,65 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-19 16:18:05
+ */
+
+package rbac
+
+import "framework/pkg/mysql"
+
+// 仓库
+type repository struct {
+	userRole       mysql.Repository[UserRole]       // 用户角色
+	rolePermission mysql.Repository[RolePermission] // 角色权限
+}
+
+// 创建仓库，按实例迁移以便多个数据库均建表
+func newRepository(sql *mysql.Mysql) repository {
+	sql.AutoMigrate(&UserRole{}, &RolePermission{})
+
+	return repository{
+		userRole:       mysql.NewRepository[UserRole](sql),
+		rolePermission: mysql.NewRepository[RolePermission](sql),
+	}
+}
+
+// 获取用户角色
+func (repository repository) findRoles(tenantId string, userId string) []string {
+	roles := make([]string, 0)
+
+	for _, userRole := range repository.userRole.FindAll("id ASC", "tenant_id = ? AND user_id = ?", tenantId, userId) {
+		roles = append(roles, userRole.Role)
+	}
+
+	return roles
+}
+
+// 获取角色权限
+func (repository repository) findPermissions(tenantId string, roles []string) []string {
+	permissions := make([]string, 0)
+
+	if len(roles) == 0 {
+		return permissions
+	}
+
+	for _, rolePermission := range repository.rolePermission.FindAll("id ASC", "tenant_id = ? AND role IN ?", tenantId, roles) {
+		permissions = append(permissions, rolePermission.Permission)
+	}
+
+	return permissions
+}
+
+// 删除用户角色
+func (repository repository) deleteUserRole(tenantId string, userId string, role string) {
+	if db := repository.userRole.Mysql.DB.Where("tenant_id = ? AND user_id = ? AND role = ?", tenantId, userId, role).Delete(&UserRole{}); db.Error != nil {
+		panic(db.Error.Error())
+	}
+}
+
+// 删除角色权限
+func (repository repository) deleteRolePermission(tenantId string, role string, permission string) {
+	if db := repository.rolePermission.Mysql.DB.Where("tenant_id = ? AND role = ? AND permission = ?", tenantId, role, permission).Delete(&RolePermission{}); db.Error != nil {
+		panic(db.Error.Error())
+	}
+}
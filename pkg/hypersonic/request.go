@@ -7,9 +7,13 @@
 package hypersonic
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"framework/pkg/trace"
 	"github.com/gin-gonic/gin/binding"
 	"net/http"
+	"reflect"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -17,18 +21,20 @@ import (
 
 // RequestModel 请求模型
 type RequestModel struct {
-	Ip       string      `json:"ip"  gorm:"type:char(15); comment:ip 地址"`                // ip 地址
-	Uri      string      `json:"uri"  gorm:"type:varchar(2083); comment:访问地址"`           // 访问地址
-	Method   string      `json:"method"  gorm:"type:char(14); comment:请求方式"`             // 请求方式
-	Header   http.Header `json:"header" gorm:"-"`                                        // 首部
-	Body     *string     `json:"body,omitempty"  gorm:"type:longtext; comment:请求体"`      // 请求体
-	Response *string     `json:"response,omitempty"  gorm:"type:longtext; comment:返回结果"` // 返回结果
-	UserId   *string     `json:"userId,omitempty" gorm:"type:char(16); comment:用户 id"`   // 用户 id
-	Elapsed  int64       `json:"elapsed" gorm:"type:int; comment:耗时"`                    // 耗时
+	RequestId string      `json:"requestId" gorm:"type:varchar(128); comment:请求 id"`      // 请求 id
+	Ip        string      `json:"ip"  gorm:"type:char(15); comment:ip 地址"`                // ip 地址
+	Uri       string      `json:"uri"  gorm:"type:varchar(2083); comment:访问地址"`           // 访问地址
+	Method    string      `json:"method"  gorm:"type:char(14); comment:请求方式"`             // 请求方式
+	Header    http.Header `json:"header" gorm:"-"`                                        // 首部
+	Body      *string     `json:"body,omitempty"  gorm:"type:longtext; comment:请求体"`      // 请求体
+	Response  *string     `json:"response,omitempty"  gorm:"type:longtext; comment:返回结果"` // 返回结果
+	UserId    *string     `json:"userId,omitempty" gorm:"type:char(16); comment:用户 id"`   // 用户 id
+	Elapsed   int64       `json:"elapsed" gorm:"type:int; comment:耗时"`                    // 耗时
 }
 
 // Filed 填充请求模型
 func (requestModel *RequestModel) Filed(req *Request, userId *string, response *string, elapsed int64) {
+	requestModel.RequestId = req.GetRequestId()
 	requestModel.Ip = req.GetIp()
 	requestModel.Uri = req.GetUri()
 	requestModel.Method = req.GetMethod()
@@ -49,6 +55,9 @@ func (requestModel *RequestModel) String() string {
 	// 空一行
 	buffer.WriteString("\n")
 
+	// 请求 id
+	buffer.WriteString("requestId: " + requestModel.RequestId + "\n")
+
 	// header
 	for key, values := range requestModel.Header {
 		for _, value := range values {
@@ -101,15 +110,20 @@ const (
 
 // Request gin 请求
 type Request struct {
-	ctx   *gin.Context // 上下文
-	Token Token        // 令牌
+	ctx        *gin.Context // 上下文
+	hypersonic *Hypersonic  // 服务
+	Token      Token        // 令牌
+
+	parameterType reflect.Type // 路由参数类型
+	parameter     any          // 已绑定的路由参数
 }
 
 // 创建请求
-func newRequest(ctx *gin.Context) *Request {
+func newRequest(ctx *gin.Context, hypersonic *Hypersonic) *Request {
 	return &Request{
-		ctx:   ctx,
-		Token: newToken(ctx),
+		ctx:        ctx,
+		hypersonic: hypersonic,
+		Token:      newToken(ctx, hypersonic),
 	}
 }
 
@@ -129,26 +143,28 @@ func (req *Request) GetUri() string {
 	}(req.ctx) + req.ctx.Request.Host + req.ctx.Request.RequestURI
 }
 
-// GetIp 获取 ip
+// GetIp 获取 ip，仅信任 Config.TrustedProxies 转发的首部
 func (req *Request) GetIp() (ip string) {
 	return req.ctx.ClientIP()
 }
 
-// Bind 绑定
+// Bind 绑定，解析不经过 gin 验证器，由请求语言验证
 func (req *Request) Bind(param any) *Error {
-	var bindingType binding.Binding
-	if req.ctx.Request.Method == http.MethodGet {
-		bindingType = binding.Query
-	} else {
-		bindingType = binding.JSON
-	}
-
 	// 解析
-	if err := req.ctx.ShouldBindWith(param, bindingType); err != nil {
-		return NewErrorWithMessage(CodeParameterError, err.Error())
+	if req.ctx.Request.Method == http.MethodGet {
+		if err := binding.MapFormWithTag(param, req.ctx.Request.URL.Query(), "form"); err != nil {
+			return parameterError(err)
+		}
+	} else if isForm(req.ctx) {
+		if err := req.bindForm(param); err != nil {
+			return err
+		}
+	} else if err := json.NewDecoder(req.ctx.Request.Body).Decode(param); err != nil {
+		return bodyError(err, req.hypersonic.getBody(req.ctx).maxBodySize)
 	}
 
-	return nil
+	// 验证
+	return req.validate(param)
 }
 
 // GetCookie 获取 cookie
@@ -189,15 +205,36 @@ func (req *Request) GetBody() *string {
 	}
 }
 
-// GetLang 获取语言
+// GetLang 获取语言，首次调用时按 Config.Lang 协商并缓存于请求
 func (req *Request) GetLang() Lang {
-	if lang := req.ctx.GetHeader("Accept-Language"); lang == "" {
-		return LangEn
-	} else if strings.Index(lang, string(LangZhCN)) != -1 {
-		return LangZhCN
+	if lang, ok := req.ctx.Get(langTag); ok {
+		return lang.(Lang)
 	}
 
-	return LangEn
+	lang := req.hypersonic.negotiateLang(req)
+	req.ctx.Set(langTag, lang)
+
+	return lang
+}
+
+// SetLang 设置语言，如中间件按租户指定
+func (req *Request) SetLang(lang Lang) {
+	req.ctx.Set(langTag, lang)
+}
+
+// GetRequestId 获取请求 id，接受上游 X-Request-ID 或生成
+func (req *Request) GetRequestId() string {
+	return getTrace(req.ctx).RequestId
+}
+
+// GetTrace 获取链路
+func (req *Request) GetTrace() trace.Trace {
+	return getTrace(req.ctx)
+}
+
+// GetContext 获取上下文，含请求链路，用于 mysql.WithContext、amqp.PublishContext 与 slog.*Context
+func (req *Request) GetContext() context.Context {
+	return req.ctx.Request.Context()
 }
 
 // GetElapsed 获取耗时
@@ -206,17 +243,24 @@ func (req *Request) GetElapsed() int64 {
 }
 
 // 获取响应
-func (req *Request) reply(data *Data, err *Error, i18n *I18n, listener Listener) {
+func (req *Request) reply(data *Data, err *Error) {
 	if data != nil {
-		req.ctx.AbortWithStatusJSON(http.StatusOK, *data)
+		if err = data.getResponse().render(req.ctx); err != nil {
+			data = nil
+		}
 	}
 
-	if err != nil {
-		err.i18n(string(req.GetLang()), i18n)
-		req.ctx.AbortWithStatusJSON(http.StatusBadRequest, *err)
+	if err != nil && err.Code == CodeNoContent {
+		req.ctx.AbortWithStatus(req.hypersonic.status.get(err.Code))
+	} else if err != nil {
+		err.i18n(string(req.GetLang()), req.hypersonic.i18n, req.hypersonic.codes)
+
+		reply := *err
+		reply.RequestId = req.GetRequestId()
+		req.abortWithError(&reply)
 	}
 
-	listener.OnLog(req, data, err)
+	req.hypersonic.onLog(req, data, err)
 }
 
 // TokenRequestMiddleware token 请求中间件
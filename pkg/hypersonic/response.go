@@ -0,0 +1,251 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-27 15:20:34
+ */
+
+package hypersonic
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 响应，非 json 数据通过 Data 携带
+type response interface {
+	render(ctx *gin.Context) *Error // 输出
+	String() string                 // 日志描述
+}
+
+// json 响应
+type jsonResponse struct {
+	data *Data // 数据
+}
+
+// 输出
+func (jsonResponse jsonResponse) render(ctx *gin.Context) *Error {
+	ctx.AbortWithStatusJSON(http.StatusOK, *jsonResponse.data)
+	return nil
+}
+
+// String 日志描述
+func (jsonResponse jsonResponse) String() string {
+	return jsonResponse.data.String()
+}
+
+// 字节响应
+type bytesResponse struct {
+	contentType string // 内容类型
+	data        []byte // 数据
+}
+
+// NewBytes 创建字节响应，如 CSV 导出、图片
+func NewBytes(contentType string, data []byte) *Data {
+	return &Data{response: bytesResponse{
+		contentType: contentType,
+		data:        data,
+	}}
+}
+
+// 输出
+func (bytesResponse bytesResponse) render(ctx *gin.Context) *Error {
+	ctx.Data(http.StatusOK, bytesResponse.contentType, bytesResponse.data)
+	ctx.Abort()
+	return nil
+}
+
+// String 日志描述
+func (bytesResponse bytesResponse) String() string {
+	return fmt.Sprintf("<bytes %s %d>", bytesResponse.contentType, len(bytesResponse.data))
+}
+
+// 文件响应
+type fileResponse struct {
+	name       string        // 文件名
+	path       string        // 文件路径，为空时使用 content
+	content    io.ReadSeeker // 内容
+	modTime    time.Time     // 修改时间
+	attachment bool          // 是否作为附件下载
+}
+
+// NewFile 创建文件响应，支持 Range 与条件请求
+func NewFile(path string) *Data {
+	return &Data{response: fileResponse{
+		name: filepath.Base(path),
+		path: path,
+	}}
+}
+
+// NewAttachment 创建附件响应，浏览器以 name 为文件名下载
+func NewAttachment(path string, name string) *Data {
+	return &Data{response: fileResponse{
+		name:       name,
+		path:       path,
+		attachment: true,
+	}}
+}
+
+// NewContent 创建内容响应，如 Storage.Open 打开的文件，支持 Range，实现 io.Closer 时输出后关闭
+func NewContent(name string, modTime time.Time, content io.ReadSeeker, attachment bool) *Data {
+	return &Data{response: fileResponse{
+		name:       name,
+		content:    content,
+		modTime:    modTime,
+		attachment: attachment,
+	}}
+}
+
+// 输出
+func (fileResponse fileResponse) render(ctx *gin.Context) *Error {
+	content := fileResponse.content
+
+	if fileResponse.path != "" {
+		file, err := os.Open(fileResponse.path)
+		if err != nil {
+			return NewErrorWithArgv(CodeNotFound, ctx.Request.URL.RequestURI())
+		}
+
+		stat, err := file.Stat()
+		if err != nil || stat.IsDir() {
+			_ = file.Close()
+			return NewErrorWithArgv(CodeNotFound, ctx.Request.URL.RequestURI())
+		}
+
+		content = file
+		fileResponse.modTime = stat.ModTime()
+	}
+
+	if closer, ok := content.(io.Closer); ok {
+		defer func() {
+			_ = closer.Close()
+		}()
+	}
+
+	if fileResponse.attachment {
+		ctx.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": fileResponse.name,
+		}))
+	}
+
+	http.ServeContent(ctx.Writer, ctx.Request, fileResponse.name, fileResponse.modTime, content)
+	ctx.Abort()
+	return nil
+}
+
+// String 日志描述
+func (fileResponse fileResponse) String() string {
+	return fmt.Sprintf("<file %s>", fileResponse.name)
+}
+
+// 流响应
+type streamResponse struct {
+	contentType string    // 内容类型
+	reader      io.Reader // 流
+}
+
+// NewStream 创建流响应，边读边写，实现 io.Closer 时输出后关闭
+func NewStream(contentType string, reader io.Reader) *Data {
+	return &Data{response: streamResponse{
+		contentType: contentType,
+		reader:      reader,
+	}}
+}
+
+// 输出，响应头已发出，复制失败时仅中断连接
+func (streamResponse streamResponse) render(ctx *gin.Context) *Error {
+	if closer, ok := streamResponse.reader.(io.Closer); ok {
+		defer func() {
+			_ = closer.Close()
+		}()
+	}
+
+	ctx.Header("Content-Type", streamResponse.contentType)
+	ctx.Status(http.StatusOK)
+
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := streamResponse.reader.Read(buffer)
+		if n > 0 {
+			if _, writeErr := ctx.Writer.Write(buffer[:n]); writeErr != nil {
+				break
+			}
+			ctx.Writer.Flush()
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	ctx.Abort()
+	return nil
+}
+
+// String 日志描述
+func (streamResponse streamResponse) String() string {
+	return fmt.Sprintf("<stream %s>", streamResponse.contentType)
+}
+
+// 重定向响应
+type redirectResponse struct {
+	status   int    // 状态码
+	location string // 地址
+}
+
+// NewRedirect 创建重定向响应，status 为 3xx，如 http.StatusFound
+func NewRedirect(status int, location string) *Data {
+	if status < http.StatusMultipleChoices || status > http.StatusPermanentRedirect {
+		panic("hypersonic redirect status must be 3xx")
+	}
+
+	return &Data{response: redirectResponse{
+		status:   status,
+		location: location,
+	}}
+}
+
+// 输出
+func (redirectResponse redirectResponse) render(ctx *gin.Context) *Error {
+	ctx.Redirect(redirectResponse.status, redirectResponse.location)
+	ctx.Abort()
+	return nil
+}
+
+// String 日志描述
+func (redirectResponse redirectResponse) String() string {
+	return fmt.Sprintf("<redirect %d %s>", redirectResponse.status, redirectResponse.location)
+}
+
+// 无内容响应
+type noContentResponse struct{}
+
+// NewNoContent 创建无内容响应，返回 204
+func NewNoContent() *Data {
+	return &Data{response: noContentResponse{}}
+}
+
+// 输出
+func (noContentResponse) render(ctx *gin.Context) *Error {
+	ctx.AbortWithStatus(http.StatusNoContent)
+	return nil
+}
+
+// String 日志描述
+func (noContentResponse) String() string {
+	return "<no content>"
+}
+
+// 获取响应
+func (data *Data) getResponse() response {
+	if data.response == nil {
+		return jsonResponse{data: data}
+	}
+
+	return data.response
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-17 21:14:37
+ */
+
+package hypersonic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"framework/pkg/redis"
+	"framework/pkg/utils"
+	"strconv"
+	"time"
+)
+
+// TokenPair 令牌对
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`  // 访问令牌
+	RefreshToken string `json:"refreshToken"` // 刷新令牌
+	ExpiresIn    int64  `json:"expiresIn"`    // 访问令牌有效期（秒）
+}
+
+// Session 会话
+type Session struct {
+	Id          string          `json:"id"`               // 会话 id
+	UserId      string          `json:"userId"`           // 用户 id
+	Device      json.RawMessage `json:"device,omitempty"` // 设备信息，如 mysql.ModelRefer
+	Ip          string          `json:"ip"`               // ip 地址
+	UserAgent   string          `json:"userAgent"`        // 用户代理
+	CreatedAt   int64           `json:"createdAt"`        // 创建时间（毫秒）
+	RefreshedAt int64           `json:"refreshedAt"`      // 刷新时间（毫秒）
+	ExpiredAt   int64           `json:"expiredAt"`        // 过期时间（毫秒）
+}
+
+// 会话记录
+type sessionRecord struct {
+	Session
+	RefreshToken string `json:"refreshToken"` // 当前刷新令牌
+}
+
+// 刷新令牌负荷
+type refreshPayload struct {
+	UserId    string `json:"userId"`    // 用户 id
+	SessionId string `json:"sessionId"` // 会话 id
+}
+
+// 令牌会话，基于 redis 实现刷新与吊销
+type tokenSessions struct {
+	redis  *redis.Redis // 缓存
+	config *TokenConfig // 令牌配置
+}
+
+// 创建令牌会话，未配置 redis 时不启用
+func newTokenSessions(config *TokenConfig) *tokenSessions {
+	if config.AccessMaxAge == 0 {
+		config.AccessMaxAge = 2 * time.Hour
+	}
+
+	if config.RefreshMaxAge == 0 {
+		config.RefreshMaxAge = 30 * 24 * time.Hour
+	}
+
+	if config.RefreshCookieName == "" {
+		config.RefreshCookieName = config.CookieName + "_refresh"
+	}
+
+	if config.Redis == nil {
+		return nil
+	}
+
+	return &tokenSessions{
+		redis:  config.Redis,
+		config: config,
+	}
+}
+
+// 会话未启用
+var errSessionsDisabled = errors.New("token sessions require redis")
+
+// 获取用户代数 Key
+func (tokenSessions *tokenSessions) getGenerationKey(userId string) string {
+	return fmt.Sprintf("Token:Generation:%s", userId)
+}
+
+// 获取吊销 Key
+func (tokenSessions *tokenSessions) getRevokedKey(sessionId string) string {
+	return fmt.Sprintf("Token:Revoked:%s", sessionId)
+}
+
+// 获取会话 Key
+func (tokenSessions *tokenSessions) getSessionKey(userId string) string {
+	return fmt.Sprintf("Token:Session:%s", userId)
+}
+
+// 获取刷新令牌 Key
+func (tokenSessions *tokenSessions) getRefreshKey(refreshToken string) string {
+	return fmt.Sprintf("Token:Refresh:%s", refreshToken)
+}
+
+// 获取用户代数，吊销所有会话时递增
+func (tokenSessions *tokenSessions) generation(userId string) int64 {
+	if b, ok := tokenSessions.redis.Get(tokenSessions.getGenerationKey(userId)); ok {
+		generation, _ := strconv.ParseInt(string(b), 10, 64)
+		return generation
+	}
+
+	return 0
+}
+
+// 是否有效
+func (tokenSessions *tokenSessions) valid(payload tokenPayload) bool {
+	if payload.SessionId != "" && tokenSessions.redis.Exists(tokenSessions.getRevokedKey(payload.SessionId)) {
+		return false
+	}
+
+	return payload.Generation == tokenSessions.generation(payload.UserId)
+}
+
+// 保存会话记录
+func (tokenSessions *tokenSessions) save(record sessionRecord) {
+	recordBytes, _ := json.Marshal(record)
+	sessionKey := tokenSessions.getSessionKey(record.UserId)
+
+	tokenSessions.redis.SetHash(sessionKey, record.Id, recordBytes)
+	tokenSessions.redis.SetTtl(sessionKey, tokenSessions.config.RefreshMaxAge)
+}
+
+// 获取会话记录
+func (tokenSessions *tokenSessions) get(userId string, sessionId string) *sessionRecord {
+	for _, record := range tokenSessions.list(userId) {
+		if record.Id == sessionId {
+			return &record
+		}
+	}
+
+	return nil
+}
+
+// 获取所有未过期会话记录
+func (tokenSessions *tokenSessions) list(userId string) []sessionRecord {
+	sessionKey := tokenSessions.getSessionKey(userId)
+	records := make([]sessionRecord, 0)
+
+	for sessionId, value := range tokenSessions.redis.GetHashAll(sessionKey) {
+		var record sessionRecord
+		if err := json.Unmarshal([]byte(value), &record); err != nil || record.ExpiredAt < time.Now().UnixMilli() {
+			tokenSessions.redis.DelHash(sessionKey, sessionId)
+			continue
+		}
+
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// 创建刷新令牌
+func (tokenSessions *tokenSessions) newRefreshToken(record *sessionRecord) {
+	record.RefreshToken = utils.NanoId(32)
+	record.RefreshedAt = time.Now().UnixMilli()
+	record.ExpiredAt = time.Now().Add(tokenSessions.config.RefreshMaxAge).UnixMilli()
+
+	tokenSessions.redis.SetJson(tokenSessions.getRefreshKey(record.RefreshToken), refreshPayload{
+		UserId:    record.UserId,
+		SessionId: record.Id,
+	}, tokenSessions.config.RefreshMaxAge)
+}
+
+// 创建会话
+func (tokenSessions *tokenSessions) create(session Session) sessionRecord {
+	session.Id = utils.NanoId(16)
+	session.CreatedAt = time.Now().UnixMilli()
+
+	record := sessionRecord{Session: session}
+	tokenSessions.newRefreshToken(&record)
+	tokenSessions.save(record)
+
+	return record
+}
+
+// 刷新会话，刷新令牌仅能使用一次
+func (tokenSessions *tokenSessions) refresh(refreshToken string) *sessionRecord {
+	refreshKey := tokenSessions.getRefreshKey(refreshToken)
+
+	var payload refreshPayload
+	if !tokenSessions.redis.GetJson(refreshKey, &payload) || !tokenSessions.redis.Del(refreshKey) {
+		return nil
+	}
+
+	record := tokenSessions.get(payload.UserId, payload.SessionId)
+	if record == nil || record.RefreshToken != refreshToken {
+		return nil
+	}
+
+	tokenSessions.newRefreshToken(record)
+	tokenSessions.save(*record)
+
+	return record
+}
+
+// 吊销会话
+func (tokenSessions *tokenSessions) revoke(userId string, sessionId string) bool {
+	record := tokenSessions.get(userId, sessionId)
+	if record == nil {
+		return false
+	}
+
+	tokenSessions.redis.Set(tokenSessions.getRevokedKey(sessionId), []byte("1"), tokenSessions.config.AccessMaxAge)
+	tokenSessions.redis.Del(tokenSessions.getRefreshKey(record.RefreshToken))
+	tokenSessions.redis.DelHash(tokenSessions.getSessionKey(userId), sessionId)
+
+	return true
+}
+
+// 吊销用户所有会话
+func (tokenSessions *tokenSessions) revokeAll(userId string) {
+	tokenSessions.redis.SetIncr(tokenSessions.getGenerationKey(userId))
+
+	for _, record := range tokenSessions.list(userId) {
+		tokenSessions.redis.Del(tokenSessions.getRefreshKey(record.RefreshToken))
+	}
+
+	tokenSessions.redis.Del(tokenSessions.getSessionKey(userId))
+}
+
+// GetSessions 获取用户所有会话
+func (hypersonic *Hypersonic) GetSessions(userId string) []Session {
+	if hypersonic.tokenSessions == nil {
+		panic(errSessionsDisabled.Error())
+	}
+
+	sessions := make([]Session, 0)
+	for _, record := range hypersonic.tokenSessions.list(userId) {
+		sessions = append(sessions, record.Session)
+	}
+
+	return sessions
+}
+
+// RevokeSession 吊销用户会话
+func (hypersonic *Hypersonic) RevokeSession(userId string, sessionId string) bool {
+	if hypersonic.tokenSessions == nil {
+		panic(errSessionsDisabled.Error())
+	}
+
+	return hypersonic.tokenSessions.revoke(userId, sessionId)
+}
+
+// RevokeAllSessions 吊销用户所有会话，已签发的令牌全部失效
+func (hypersonic *Hypersonic) RevokeAllSessions(userId string) {
+	if hypersonic.tokenSessions == nil {
+		panic(errSessionsDisabled.Error())
+	}
+
+	hypersonic.tokenSessions.revokeAll(userId)
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-29 16:27:14
+ */
+
+package hypersonic
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"sync"
+	"time"
+)
+
+// SocketFunc WebSocket 路由处理回调，返回后断开连接，仅推送时以 socket.Wait() 结束
+type SocketFunc func(req *Request, socket *Socket) *Error
+
+// Socket WebSocket 连接
+type Socket struct {
+	conn    *websocket.Conn    // 连接
+	mutex   sync.Mutex         // 写锁
+	context context.Context    // 上下文，连接断开或服务关闭时结束
+	cancel  context.CancelFunc // 结束
+}
+
+// Read 读取消息
+func (socket *Socket) Read() ([]byte, error) {
+	_, data, err := socket.conn.ReadMessage()
+	if err != nil {
+		socket.cancel()
+	}
+
+	return data, err
+}
+
+// ReadJson 读取 json 消息
+func (socket *Socket) ReadJson(v any) error {
+	err := socket.conn.ReadJSON(v)
+	if err != nil {
+		socket.cancel()
+	}
+
+	return err
+}
+
+// Write 写入文本消息
+func (socket *Socket) Write(data []byte) error {
+	socket.mutex.Lock()
+	defer socket.mutex.Unlock()
+
+	_ = socket.conn.SetWriteDeadline(time.Now().Add(heartbeatInterval))
+	return socket.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// WriteJson 写入 json 消息
+func (socket *Socket) WriteJson(v any) error {
+	socket.mutex.Lock()
+	defer socket.mutex.Unlock()
+
+	_ = socket.conn.SetWriteDeadline(time.Now().Add(heartbeatInterval))
+	return socket.conn.WriteJSON(v)
+}
+
+// Done 连接断开或服务关闭
+func (socket *Socket) Done() <-chan struct{} {
+	return socket.context.Done()
+}
+
+// Wait 读取并丢弃客户端消息，直到连接断开或服务关闭
+func (socket *Socket) Wait() *Error {
+	for {
+		if _, err := socket.Read(); err != nil {
+			return nil
+		}
+	}
+}
+
+// 投递推送消息并定时发送心跳
+func (socket *Socket) pump(client *hubClient) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-socket.Done():
+			return
+		case message := <-client.messages:
+			if socket.WriteJson(message) != nil {
+				socket.cancel()
+			}
+		case <-ticker.C:
+			if socket.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(heartbeatInterval)) != nil {
+				socket.cancel()
+			}
+		}
+	}
+}
+
+// 执行 WebSocket 路由，连接按用户 id 加入推送中心
+func (req *Request) serveSocket(socketFunc SocketFunc) {
+	ctx := req.ctx
+	hub := req.hypersonic.hub
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: hub.config.CheckOrigin,
+	}
+
+	// 升级失败时已响应 http 错误
+	conn, upgradeErr := upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if upgradeErr != nil {
+		ctx.Abort()
+		req.hypersonic.onLog(req, nil, NewErrorWithMessage(CodeParameterError, upgradeErr.Error()))
+		return
+	}
+
+	socket := &Socket{
+		conn: conn,
+	}
+	socket.context, socket.cancel = context.WithCancel(context.Background())
+
+	// 超过两个心跳周期未收到消息视为断开
+	_ = conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * heartbeatInterval))
+	})
+
+	go func() {
+		select {
+		case <-hub.closed:
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(time.Second))
+			socket.cancel()
+			_ = conn.Close()
+		case <-socket.Done():
+		}
+	}()
+
+	client := hub.add(req.getHubUserId())
+
+	waitGroup := sync.WaitGroup{}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		socket.pump(client)
+	}()
+
+	err := req.invokeStream(func() *Error {
+		return socketFunc(req, socket)
+	})
+
+	hub.remove(client)
+	socket.cancel()
+	waitGroup.Wait()
+
+	// 连接已建立，错误以关闭码 4000 + http 状态发送，原因为错误码
+	closeMessage := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err != nil {
+		closeMessage = websocket.FormatCloseMessage(4000+req.hypersonic.status.get(err.Code), string(err.Code))
+	}
+
+	_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+	_ = conn.Close()
+
+	ctx.Abort()
+	req.hypersonic.onLog(req, streamData(err, "websocket"), err)
+}
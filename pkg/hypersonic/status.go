@@ -0,0 +1,63 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-15 09:42:18
+ */
+
+package hypersonic
+
+import (
+	"net/http"
+	"sync"
+)
+
+// 错误码 http 状态注册表，覆盖错误码注册表的状态
+type statusRegistry struct {
+	mutex  *sync.RWMutex // 锁
+	status map[Code]int  // 状态
+	codes  *CodeRegistry // 错误码注册表
+}
+
+// 创建错误码 http 状态注册表
+func newStatusRegistry(codes *CodeRegistry) statusRegistry {
+	return statusRegistry{
+		mutex:  &sync.RWMutex{},
+		status: make(map[Code]int),
+		codes:  codes,
+	}
+}
+
+// 设置
+func (statusRegistry statusRegistry) set(code Code, httpStatus int) {
+	statusRegistry.mutex.Lock()
+	defer statusRegistry.mutex.Unlock()
+
+	statusRegistry.status[code] = httpStatus
+}
+
+// 获取，依次为覆盖的状态、注册的状态，未注册的错误码为 400
+func (statusRegistry statusRegistry) get(code Code) int {
+	statusRegistry.mutex.RLock()
+	httpStatus, ok := statusRegistry.status[code]
+	statusRegistry.mutex.RUnlock()
+
+	if ok {
+		return httpStatus
+	}
+
+	if info, ok := statusRegistry.codes.Get(code); ok && info.Status != 0 {
+		return info.Status
+	}
+
+	return http.StatusBadRequest
+}
+
+// SetStatus 设置本服务的错误码 http 状态，覆盖注册的状态，如内置错误码，新错误码应以 RegisterCode 注册状态
+func (hypersonic *Hypersonic) SetStatus(code Code, httpStatus int) {
+	hypersonic.status.set(code, httpStatus)
+}
+
+// GetStatus 获取错误码 http 状态
+func (hypersonic *Hypersonic) GetStatus(code Code) int {
+	return hypersonic.status.get(code)
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-26 10:12:45
+ */
+
+package hypersonic
+
+import (
+	"errors"
+	"framework/pkg/utils"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Storage 文件存储
+type Storage interface {
+	// Save 保存文件，name 为原始文件名，返回存储 key
+	Save(name string, reader io.Reader) (key string, err error)
+	// Open 打开文件
+	Open(key string) (io.ReadCloser, error)
+	// Delete 删除文件
+	Delete(key string) error
+}
+
+// 非法存储 key
+var errStorageKey = errors.New("invalid storage key")
+
+// LocalStorage 本地磁盘存储
+type LocalStorage struct {
+	dir string // 根目录
+}
+
+// NewLocalStorage 创建本地磁盘存储
+func NewLocalStorage(dir string) (*LocalStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalStorage{
+		dir: dir,
+	}, nil
+}
+
+// 获取文件路径，拒绝越出根目录的 key
+func (localStorage *LocalStorage) path(key string) (string, error) {
+	if !filepath.IsLocal(filepath.FromSlash(key)) {
+		return "", errStorageKey
+	}
+
+	return filepath.Join(localStorage.dir, filepath.FromSlash(key)), nil
+}
+
+// Save 保存文件，按日期分目录并使用随机文件名，仅保留原始扩展名
+func (localStorage *LocalStorage) Save(name string, reader io.Reader) (string, error) {
+	key := time.Now().Format("2006/01/02") + "/" + utils.NanoId(16) + strings.ToLower(filepath.Ext(filepath.Base(name)))
+
+	path, err := localStorage.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = io.Copy(file, reader); err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	if err = file.Close(); err != nil {
+		_ = os.Remove(path)
+		return "", err
+	}
+
+	return key, nil
+}
+
+// Open 打开文件
+func (localStorage *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := localStorage.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+// Delete 删除文件
+func (localStorage *LocalStorage) Delete(key string) error {
+	path, err := localStorage.path(key)
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,443 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-14 10:21:36
+ */
+
+package swagger
+
+import (
+	"encoding/json"
+	"mime/multipart"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// https://spec.openapis.org/oas/v3.0.3
+
+// Schema 结构
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`                 // 引用
+	Type                 string             `json:"type,omitempty"`                 // 类型
+	Format               string             `json:"format,omitempty"`               // 格式
+	Description          string             `json:"description,omitempty"`          // 描述
+	Nullable             bool               `json:"nullable,omitempty"`             // 可空
+	Enum                 []any              `json:"enum,omitempty"`                 // 枚举
+	Minimum              *float64           `json:"minimum,omitempty"`              // 最小值
+	Maximum              *float64           `json:"maximum,omitempty"`              // 最大值
+	MinLength            *int               `json:"minLength,omitempty"`            // 最小长度
+	MaxLength            *int               `json:"maxLength,omitempty"`            // 最大长度
+	Pattern              string             `json:"pattern,omitempty"`              // 正则
+	Items                *Schema            `json:"items,omitempty"`                // 数组元素
+	Properties           map[string]*Schema `json:"properties,omitempty"`           // 属性
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"` // 附加属性
+	Required             []string           `json:"required,omitempty"`             // 必填
+}
+
+// Parameter 参数
+type Parameter struct {
+	Name     string  `json:"name"`               // 名称
+	In       string  `json:"in"`                 // 位置 path、query、header、cookie
+	Required bool    `json:"required,omitempty"` // 必填
+	Schema   *Schema `json:"schema"`             // 结构
+}
+
+// MediaType 媒体类型
+type MediaType struct {
+	Schema *Schema `json:"schema"` // 结构
+}
+
+// RequestBody 请求体
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"` // 必填
+	Content  map[string]MediaType `json:"content"`            // 内容
+}
+
+// Response 响应
+type Response struct {
+	Description string               `json:"description"`       // 描述
+	Content     map[string]MediaType `json:"content,omitempty"` // 内容
+}
+
+// Operation 操作
+type Operation struct {
+	Tags        []string            `json:"tags,omitempty"`        // 标签
+	Summary     string              `json:"summary,omitempty"`     // 摘要
+	OperationId string              `json:"operationId,omitempty"` // 操作 id
+	Parameters  []Parameter         `json:"parameters,omitempty"`  // 参数
+	RequestBody *RequestBody        `json:"requestBody,omitempty"` // 请求体
+	Responses   map[string]Response `json:"responses"`             // 响应
+}
+
+// Info 信息
+type Info struct {
+	Title   string `json:"title"`   // 标题
+	Version string `json:"version"` // 版本
+}
+
+// Components 组件
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"` // 结构
+}
+
+// OpenApi 文档
+type OpenApi struct {
+	OpenApi    string                           `json:"openapi"`    // 版本
+	Info       Info                             `json:"info"`       // 信息
+	Paths      map[string]map[string]*Operation `json:"paths"`      // 路径
+	Components Components                       `json:"components"` // 组件
+
+	schemaNames map[reflect.Type]string // 类型结构名称，同名类型加后缀区分
+}
+
+// NewOpenApi 创建文档
+func NewOpenApi(title string, version string) *OpenApi {
+	return &OpenApi{
+		OpenApi: "3.0.3",
+		Info: Info{
+			Title:   title,
+			Version: version,
+		},
+		Paths: make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: make(map[string]*Schema),
+		},
+		schemaNames: make(map[reflect.Type]string),
+	}
+}
+
+// Json 序列化
+func (openApi *OpenApi) Json() []byte {
+	openApiBytes, _ := json.Marshal(openApi)
+	return openApiBytes
+}
+
+// gin 路径参数，如 :id 或 *any
+var pathParamRegexp = regexp.MustCompile(`[:*]([^/]+)`)
+
+// 结构名称非法字符
+var schemaNameRegexp = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// AddOperation 添加操作
+func (openApi *OpenApi) AddOperation(method string, path string, tag string, operation *Operation) {
+	for _, match := range pathParamRegexp.FindAllStringSubmatch(path, -1) {
+		operation.Parameters = append(operation.Parameters, Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	path = pathParamRegexp.ReplaceAllString(path, "{$1}")
+
+	if tag != "" {
+		operation.Tags = []string{tag}
+	}
+
+	if operation.OperationId == "" {
+		operation.OperationId = strings.ToLower(method) + strings.NewReplacer("/", "_", "{", "", "}", "").Replace(path)
+	}
+
+	if openApi.Paths[path] == nil {
+		openApi.Paths[path] = make(map[string]*Operation)
+	}
+
+	openApi.Paths[path][strings.ToLower(method)] = operation
+}
+
+// QueryParameters 结构体字段作为 query 参数
+func (openApi *OpenApi) QueryParameters(t reflect.Type) []Parameter {
+	return openApi.parameters(t, "form", "query", false)
+}
+
+// TagParameters 声明了标签的结构体字段作为参数，如 header 标签作为首部参数
+func (openApi *OpenApi) TagParameters(t reflect.Type, tagKey string, in string) []Parameter {
+	return openApi.parameters(t, tagKey, in, true)
+}
+
+// 结构体字段作为参数
+func (openApi *OpenApi) parameters(t reflect.Type, tagKey string, in string, tagged bool) []Parameter {
+	parameters := make([]Parameter, 0)
+
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return parameters
+	}
+
+	openApi.eachField(t, tagKey, func(name string, field reflect.StructField) {
+		if _, ok := field.Tag.Lookup(tagKey); tagged && !ok {
+			return
+		}
+
+		// 未声明标签时跳过路径与首部参数
+		if _, ok := field.Tag.Lookup("uri"); !tagged && ok {
+			return
+		}
+		if _, ok := field.Tag.Lookup("header"); !tagged && ok {
+			return
+		}
+
+		schema, required := openApi.fieldSchema(field)
+		parameters = append(parameters, Parameter{
+			Name:     name,
+			In:       in,
+			Required: required,
+			Schema:   schema,
+		})
+	})
+
+	return parameters
+}
+
+// FormSchema 结构体 form 标签字段作为表单结构，跳过路径与首部参数
+func (openApi *OpenApi) FormSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	for _, parameter := range openApi.parameters(t, "form", "", false) {
+		schema.Properties[parameter.Name] = parameter.Schema
+
+		if parameter.Required {
+			schema.Required = append(schema.Required, parameter.Name)
+		}
+	}
+
+	return schema
+}
+
+// HasFile 结构体是否包含上传文件字段
+func HasFile(t reflect.Type) bool {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		switch {
+		case field.Type == reflect.TypeOf(&multipart.FileHeader{}),
+			field.Type == reflect.TypeOf([]*multipart.FileHeader{}):
+			return true
+		case field.Anonymous && HasFile(field.Type):
+			return true
+		}
+	}
+
+	return false
+}
+
+// SchemaOf 获取类型结构，结构体注册到组件中并返回引用
+func (openApi *OpenApi) SchemaOf(t reflect.Type) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return &Schema{Type: "string", Format: "date-time"}
+	case reflect.TypeOf(json.RawMessage{}):
+		return &Schema{}
+	case reflect.TypeOf(&multipart.FileHeader{}):
+		return &Schema{Type: "string", Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := openApi.SchemaOf(t.Elem())
+		if schema.Ref == "" {
+			schema.Nullable = true
+		}
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: openApi.SchemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: openApi.SchemaOf(t.Elem())}
+	case reflect.Struct:
+		return openApi.structSchema(t)
+	default:
+		return &Schema{}
+	}
+}
+
+// 结构体
+func (openApi *OpenApi) structSchema(t reflect.Type) *Schema {
+	// 匿名结构体直接展开
+	if t.Name() == "" {
+		return openApi.objectSchema(t)
+	}
+
+	if name, ok := openApi.schemaNames[t]; ok {
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+
+	// 不同包同名类型加序号，如 user.parameter_2
+	name := schemaName(t)
+	for i := 2; openApi.Components.Schemas[name] != nil; i++ {
+		name = schemaName(t) + "_" + strconv.Itoa(i)
+	}
+
+	openApi.schemaNames[t] = name
+	ref := &Schema{Ref: "#/components/schemas/" + name}
+
+	// 先占位避免递归类型死循环
+	openApi.Components.Schemas[name] = &Schema{Type: "object"}
+	openApi.Components.Schemas[name] = openApi.objectSchema(t)
+
+	return ref
+}
+
+// 对象
+func (openApi *OpenApi) objectSchema(t reflect.Type) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: make(map[string]*Schema),
+	}
+
+	openApi.eachField(t, "json", func(name string, field reflect.StructField) {
+		fieldSchema, required := openApi.fieldSchema(field)
+		schema.Properties[name] = fieldSchema
+
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	})
+
+	return schema
+}
+
+// 遍历字段，匿名字段与 encoding/json 一样展开
+func (openApi *OpenApi) eachField(t reflect.Type, tagKey string, fn func(name string, field reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		tag := field.Tag.Get(tagKey)
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+
+			if ft.Kind() == reflect.Struct {
+				openApi.eachField(ft, tagKey, fn)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fn(name, field)
+	}
+}
+
+// 字段结构，解析 validate 规则
+func (openApi *OpenApi) fieldSchema(field reflect.StructField) (schema *Schema, required bool) {
+	schema = openApi.SchemaOf(field.Type)
+
+	rules := field.Tag.Get("validate")
+	if rules == "" {
+		return schema, false
+	}
+
+	// 引用不能附加约束，使用 allOf 会让文档过于复杂，仅保留必填
+	if schema.Ref != "" {
+		return schema, strings.Contains("|"+rules+"|", "|required|")
+	}
+
+	for _, rule := range strings.Split(rules, "|") {
+		name, value, _ := strings.Cut(rule, ":")
+
+		switch name {
+		case "required":
+			required = true
+		case "min", "gte":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				schema.Minimum = &f
+			}
+		case "max", "lte":
+			if f, err := strconv.ParseFloat(value, 64); err == nil {
+				schema.Maximum = &f
+			}
+		case "min_len", "minLen", "minLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				schema.MinLength = &n
+			}
+		case "max_len", "maxLen", "maxLength":
+			if n, err := strconv.Atoi(value); err == nil {
+				schema.MaxLength = &n
+			}
+		case "len", "length":
+			if n, err := strconv.Atoi(value); err == nil {
+				schema.MinLength = &n
+				schema.MaxLength = &n
+			}
+		case "enum", "in":
+			for _, e := range strings.Split(value, ",") {
+				schema.Enum = append(schema.Enum, e)
+			}
+		case "email":
+			schema.Format = "email"
+		case "url", "fullUrl":
+			schema.Format = "uri"
+		case "ip":
+			schema.Format = "ip"
+		case "numeric", "number":
+			schema.Pattern = "^[0-9]+$"
+		case "ascii":
+			schema.Pattern = "^[\\x00-\\x7F]*$"
+		case "regex", "regexp":
+			schema.Pattern = value
+		}
+	}
+
+	return schema, required
+}
+
+// 结构名称，泛型参数中的特殊字符替换为下划线
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if pkg := t.PkgPath(); pkg != "" {
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+
+	return schemaNameRegexp.ReplaceAllString(name, "_")
+}
@@ -8,7 +8,6 @@ package swagger
 
 import (
 	_ "embed"
-	"os"
 )
 
 //go:embed swagger_redoc.js
@@ -17,16 +16,11 @@ var swaggerRedocJsByte []byte
 //go:embed swagger.html
 var swaggerHtmlByte []byte
 
-// Redoc 文档
-func Redoc(suffix string) []byte {
-	if suffix == "doc.json" {
-		data, _ := os.ReadFile("docs/swagger.json")
-		return data
-	}
-
+// Redoc 文档页面
+func Redoc(suffix string) (data []byte, contentType string) {
 	if suffix == "redoc.js" {
-		return swaggerRedocJsByte
+		return swaggerRedocJsByte, "application/javascript; charset=utf-8"
 	} else {
-		return swaggerHtmlByte
+		return swaggerHtmlByte, "text/html; charset=utf-8"
 	}
 }
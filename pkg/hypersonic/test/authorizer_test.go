@@ -0,0 +1,117 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-19 18:47:21
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 内存授权者
+type memoryAuthorizer map[string]hypersonic.Grants
+
+// GetGrants 获取授权
+func (memoryAuthorizer memoryAuthorizer) GetGrants(tenantId string, userId string) hypersonic.Grants {
+	return memoryAuthorizer[tenantId+":"+userId]
+}
+
+func TestAuthorizer(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener:   hypersonic.NewEchoListener(),
+		I18n:       i18n,
+		Token:      testToken,
+		TenantFunc: hypersonic.HeaderTenantFunc,
+		Authorizer: memoryAuthorizer{
+			"tenant-a:admin": {Roles: []string{"admin"}, Permissions: []string{"order:read", "order:write"}},
+			"tenant-a:clerk": {Roles: []string{"clerk"}, Permissions: []string{"order:read"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ok := func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+		return hypersonic.NewData(nil, nil), nil
+	}
+
+	h.RegisterControllers("/admin", []hypersonic.Controller{{
+		Path: "/user",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/login/:userId",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				req.Token.SetUserId(req.GetParam("userId"), time.Hour)
+				return hypersonic.NewData(nil, nil), nil
+			},
+		}},
+	}, {
+		Path:  "/orders",
+		Roles: []string{"admin", "clerk"},
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "",
+			Permissions:  []string{"order:read"},
+			InvokeFunc:   ok,
+		}, {
+			HttpMethod:   http.MethodPut,
+			RelativePath: "",
+			Permissions:  []string{"order:read", "order:write"},
+			InvokeFunc:   ok,
+		}},
+	}})
+
+	// 登录获取 cookie
+	login := func(userId string) *http.Cookie {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/user/login/"+userId, nil))
+		return w.Result().Cookies()[0]
+	}
+
+	for _, c := range []struct {
+		userId   string
+		tenantId string
+		method   string
+		status   int
+	}{
+		{"", "tenant-a", http.MethodGet, http.StatusUnauthorized},
+		{"admin", "tenant-a", http.MethodGet, http.StatusOK},
+		{"admin", "tenant-a", http.MethodPut, http.StatusOK},
+		{"clerk", "tenant-a", http.MethodGet, http.StatusOK},
+		{"clerk", "tenant-a", http.MethodPut, http.StatusForbidden},
+		{"admin", "tenant-b", http.MethodGet, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(c.method, "/admin/orders", nil)
+		req.Header.Set("X-Tenant-Id", c.tenantId)
+		if c.userId != "" {
+			req.AddCookie(login(c.userId))
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != c.status {
+			t.Errorf("%+v status %d", c, w.Code)
+		}
+	}
+
+	// 配置授权者时必须配置租户解析
+	if _, err = hypersonic.New(hypersonic.Config{
+		I18n:       i18n,
+		Token:      testToken,
+		Authorizer: memoryAuthorizer{},
+	}); err == nil {
+		t.Fatalf("authorizer without tenant func accepted")
+	}
+}
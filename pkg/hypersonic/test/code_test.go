@@ -0,0 +1,99 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-14 14:25:51
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"framework/pkg/hypersonic/test/user/user_public/post_login/post_login_name"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCode(t *testing.T) {
+	// 独立注册表，不影响全局注册表
+	codes := hypersonic.NewCodeRegistry()
+	codeOrderLocked := codes.Register("OrderLocked", http.StatusLocked, "order %s locked")
+
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+		Codes:    codes,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/code",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return nil, hypersonic.NewErrorWithArgv(codeOrderLocked, "O1")
+			},
+		}},
+	}})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public/code", nil))
+
+	var reply hypersonic.Error
+	if err = json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// 缺少翻译时使用默认消息
+	if w.Code != http.StatusLocked || reply.Message != "order O1 locked" {
+		t.Errorf("unexpected reply %d %s", w.Code, w.Body.String())
+	}
+
+	if info, ok := codes.Get(hypersonic.CodeNoAuth); !ok || info.Status != http.StatusUnauthorized {
+		t.Errorf("builtin code should be registered")
+	}
+
+	if _, ok := hypersonic.GetCode(codeOrderLocked); ok {
+		t.Errorf("code should not be registered globally")
+	}
+
+	// 各语言均缺少 OrderLocked
+	if err := codes.Check(i18n); err == nil ||
+		strings.Count(err.Error(), "OrderLocked") != 2 || strings.Contains(err.Error(), "NoAuth") {
+		t.Errorf("unexpected check %v", err)
+	}
+
+	stubs := codes.Stubs(i18n)
+	for _, lang := range i18n.Langs() {
+		if stubs[lang] != `OrderLocked = "order %s locked"`+"\n" {
+			t.Errorf("unexpected %s stub %q", lang, stubs[lang])
+		}
+	}
+}
+
+func TestCodeGlobal(t *testing.T) {
+	// 登录错误码以 RegisterCode 注册状态，测试翻译均已覆盖
+	if info, ok := hypersonic.GetCode(post_login_name.UserLoginRateLimit); !ok || info.Status != http.StatusTooManyRequests {
+		t.Errorf("unexpected login rate limit code %+v", info)
+	}
+
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if err = hypersonic.CheckCodes(i18n); err != nil {
+		t.Errorf(err.Error())
+	}
+}
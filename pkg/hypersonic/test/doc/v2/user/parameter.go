@@ -0,0 +1,13 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-15 10:12:08
+ */
+
+package user
+
+// Parameter 参数，与另一版本同名
+type Parameter struct {
+	Name string `json:"name"` // 名称
+	Age  int    `json:"age"`  // 年龄
+}
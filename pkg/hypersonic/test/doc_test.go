@@ -0,0 +1,109 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-14 14:36:12
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"framework/pkg/hypersonic/swagger"
+	userV1 "framework/pkg/hypersonic/test/doc/v1/user"
+	userV2 "framework/pkg/hypersonic/test/doc/v2/user"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type docParameter struct {
+	Name     string `json:"name" validate:"required|ascii|min_len:2|max_len:32"` // 用户名
+	Password string `json:"password" validate:"required|min_len:6"`              // 密码
+}
+
+type docResult struct {
+	UserId string `json:"userId"` // 用户 id
+}
+
+func TestOpenApi(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		IsDev:    true,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	invoke := func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+		return hypersonic.NewData(docResult{}, nil), nil
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/user",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/login/name",
+			InvokeFunc:   invoke,
+			Parameter:    docParameter{},
+			Result:       docResult{},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/:id/scores",
+			InvokeFunc:   invoke,
+			Parameter:    hypersonic.Page{},
+		}, {
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/v1",
+			InvokeFunc:   invoke,
+			Parameter:    userV1.Parameter{},
+		}, {
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/v2",
+			InvokeFunc:   invoke,
+			Parameter:    userV2.Parameter{},
+		}},
+	}})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/doc/doc.json", nil))
+
+	var openApi swagger.OpenApi
+	if err = json.Unmarshal(w.Body.Bytes(), &openApi); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	post := openApi.Paths["/public/user/login/name"]["post"]
+	if post == nil || post.RequestBody == nil {
+		t.Fatalf("post operation not found")
+	}
+
+	schema := openApi.Components.Schemas["test.docParameter"]
+	if schema == nil || len(schema.Required) != 2 || *schema.Properties["name"].MaxLength != 32 {
+		t.Fatalf("parameter schema not match")
+	}
+
+	get := openApi.Paths["/public/user/{id}/scores"]["get"]
+	if get == nil || len(get.Parameters) != 3 {
+		t.Fatalf("get operation not match")
+	}
+
+	// 不同包同名类型不覆盖
+	v1 := openApi.Components.Schemas["user.Parameter"]
+	v2 := openApi.Components.Schemas["user.Parameter_2"]
+	if v1 == nil || v2 == nil || len(v1.Properties)+len(v2.Properties) != 3 {
+		t.Fatalf("same name schemas should not collide")
+	}
+
+	v2Ref := openApi.Paths["/public/user/v2"]["post"].RequestBody.Content["application/json"].Schema.Ref
+	v1Ref := openApi.Paths["/public/user/v1"]["post"].RequestBody.Content["application/json"].Schema.Ref
+	if v1Ref == v2Ref {
+		t.Fatalf("unexpected refs %s %s", v1Ref, v2Ref)
+	}
+}
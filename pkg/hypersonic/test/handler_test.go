@@ -0,0 +1,111 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-24 16:50:38
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type handlerParameter struct {
+	OrderId string `uri:"orderId" validate:"required"`                  // 订单 id
+	Version int    `form:"version" validate:"required|min:1"`           // 版本
+	Device  string `header:"X-Device-Type"`                             // 设备类型
+	Remark  string `json:"remark" validate:"required|max_len:8"`        // 备注
+	Amount  int64  `json:"amount" validate:"required|min:1|max:100000"` // 金额
+}
+
+type handlerResult struct {
+	OrderId string `json:"orderId"` // 订单 id
+	Version int    `json:"version"` // 版本
+	Device  string `json:"device"`  // 设备类型
+	Remark  string `json:"remark"`  // 备注
+}
+
+func TestHandler(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		IsDev:    true,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/order",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPatch,
+			RelativePath: "/:orderId",
+			Handler: hypersonic.Handle(func(req *hypersonic.Request, p *handlerParameter) (*handlerResult, *hypersonic.Error) {
+				return &handlerResult{
+					OrderId: p.OrderId,
+					Version: p.Version,
+					Device:  p.Device,
+					Remark:  p.Remark,
+				}, nil
+			}),
+		}},
+	}})
+
+	// 调用
+	call := func(body string) (int, string) {
+		req := httptest.NewRequest(http.MethodPatch, "/public/order/o-1?version=3", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Device-Type", "WEB")
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code, w.Body.String()
+	}
+
+	code, body := call(`{"remark":"fast","amount":12}`)
+	if code != http.StatusOK || body != `{"data":{"orderId":"o-1","version":3,"device":"WEB","remark":"fast"}}` {
+		t.Fatalf("status %d, body %s", code, body)
+	}
+
+	if code, body = call(`{"remark":"too long remark","amount":12}`); code != http.StatusBadRequest {
+		t.Fatalf("validate status %d, body %s", code, body)
+	}
+
+	// 无 form 标签的字段不从 query 绑定，不覆盖请求体
+	req := httptest.NewRequest(http.MethodPatch, "/public/order/o-1?version=3&Remark=hack&OrderId=o-2", strings.NewReader(`{"remark":"fast","amount":12}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Body.String() != `{"data":{"orderId":"o-1","version":3,"device":"","remark":"fast"}}` {
+		t.Fatalf("query override body %s", w.Body.String())
+	}
+
+	// 文档
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/doc/doc.json", nil))
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &doc)
+
+	if parameters := doc.Paths["/public/order/{orderId}"]["patch"].Parameters; len(parameters) != 3 {
+		t.Fatalf("doc parameters %+v", parameters)
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-29 18:12:36
+ */
+
+package test
+
+import (
+	"bufio"
+	"framework/pkg/hypersonic"
+	"framework/pkg/redis"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 创建推送测试服务
+func newHubHypersonic(t *testing.T, cache *redis.Redis) *hypersonic.Hypersonic {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token: hypersonic.TokenConfig{
+			Keys: []hypersonic.TokenKey{{Id: "hub", Secret: []byte("hub-secret")}},
+		},
+		Hub: hypersonic.HubConfig{
+			Redis: cache,
+		},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/token",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/login",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				req.Token.SetUserId("user-id", time.Hour)
+				return hypersonic.NewData(nil, nil), nil
+			},
+		}},
+	}, {
+		Path: "/feed",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/events",
+			EventFunc: func(req *hypersonic.Request, stream *hypersonic.EventStream) *hypersonic.Error {
+				_ = stream.Send("ready", nil)
+				return stream.Wait()
+			},
+		}},
+	}, {
+		Path:              "/push",
+		RequestMiddleware: hypersonic.TokenRequestMiddleware,
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/events",
+			EventFunc: func(req *hypersonic.Request, stream *hypersonic.EventStream) *hypersonic.Error {
+				_ = stream.Send("ready", nil)
+				return stream.Wait()
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/socket",
+			SocketFunc: func(req *hypersonic.Request, socket *hypersonic.Socket) *hypersonic.Error {
+				_ = socket.WriteJson(hypersonic.Message{Event: "ready"})
+				return socket.Wait()
+			},
+		}},
+	}})
+
+	return h
+}
+
+// 登录获取令牌首部
+func hubLogin(t *testing.T, url string) http.Header {
+	resp, err := http.Post(url+"/public/token/login", "application/json", nil)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	_ = resp.Body.Close()
+
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "token" {
+			return http.Header{"Authorization": {"Bearer " + cookie.Value}}
+		}
+	}
+
+	t.Fatalf("login without token")
+	return nil
+}
+
+// 连接 SSE，返回事件读取
+func hubEvents(t *testing.T, url string, header http.Header) <-chan string {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header = header.Clone()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	t.Cleanup(func() {
+		_ = resp.Body.Close()
+	})
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("events content type %s", resp.Header.Get("Content-Type"))
+	}
+
+	frames := make(chan string, 8)
+	go func() {
+		defer close(frames)
+
+		events := bufio.NewReader(resp.Body)
+		frame := ""
+		for {
+			line, err := events.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "\n" {
+				frames <- frame
+				frame = ""
+				continue
+			}
+			frame += line
+		}
+	}()
+
+	return frames
+}
+
+func TestHub(t *testing.T) {
+	cache := newMiniRedis(t)
+
+	// 先于连接注册，连接关闭后再关闭服务
+	a := httptest.NewServer(newHubHypersonic(t, cache))
+	t.Cleanup(a.Close)
+	b := httptest.NewServer(newHubHypersonic(t, cache))
+	t.Cleanup(b.Close)
+
+	// 登录
+	header := hubLogin(t, a.URL)
+
+	// 未登录
+	resp, err := http.Get(b.URL + "/public/push/events")
+	if err != nil {
+		t.Fatalf(err.Error())
+	} else if _ = resp.Body.Close(); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("events without token status %d", resp.StatusCode)
+	}
+
+	// SSE 连接实例 b
+	events := hubEvents(t, b.URL+"/public/push/events", header)
+
+	if frame := <-events; !strings.HasPrefix(frame, "event: ready\n") {
+		t.Fatalf("events ready %q", frame)
+	}
+
+	// WebSocket 连接实例 a
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(a.URL, "http")+"/public/push/socket", header)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	var message hypersonic.Message
+	if err = conn.ReadJSON(&message); err != nil || message.Event != "ready" {
+		t.Fatalf("socket ready %+v, %v", message, err)
+	}
+
+	// 通过其他实例推送，两个实例的连接都收到
+	h := newHubHypersonic(t, cache)
+	h.Publish("order", map[string]int{"id": 1}, "user-id")
+	h.Publish("order", map[string]int{"id": 2}, "other-user-id")
+
+	if frame := <-events; frame != "event: order\ndata: {\"id\":1}\n" {
+		t.Fatalf("events order %q", frame)
+	}
+
+	if err = conn.ReadJSON(&message); err != nil || message.Event != "order" || string(message.Data) != `{"id":1}` {
+		t.Fatalf("socket order %+v, %v", message, err)
+	}
+}
+
+func TestHubAnonymous(t *testing.T) {
+	server := httptest.NewServer(newHubHypersonic(t, nil))
+	t.Cleanup(server.Close)
+
+	// 已登录与未登录连接
+	events := hubEvents(t, server.URL+"/public/push/events", hubLogin(t, server.URL))
+	anonymous := hubEvents(t, server.URL+"/public/feed/events", nil)
+
+	for _, frames := range []<-chan string{events, anonymous} {
+		if frame := <-frames; !strings.HasPrefix(frame, "event: ready\n") {
+			t.Fatalf("events ready %q", frame)
+		}
+	}
+
+	// 广播不推送到未登录连接
+	h := server.Config.Handler.(*hypersonic.Hypersonic)
+	h.Publish("notice", map[string]int{"id": 1})
+	h.Publish("notice", map[string]int{"id": 2}, "")
+
+	if frame := <-events; frame != "event: notice\ndata: {\"id\":1}\n" {
+		t.Fatalf("events notice %q", frame)
+	}
+
+	select {
+	case frame := <-anonymous:
+		t.Fatalf("anonymous received %q", frame)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
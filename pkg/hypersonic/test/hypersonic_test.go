@@ -7,6 +7,7 @@
 package test
 
 import (
+	"context"
 	"framework/pkg/hypersonic"
 	"framework/pkg/hypersonic/test/user/user_public"
 	"framework/pkg/mysql"
@@ -14,6 +15,11 @@ import (
 	"testing"
 )
 
+// 测试令牌配置，非开发模式必须配置 jwt 密钥
+var testToken = hypersonic.TokenConfig{
+	Keys: []hypersonic.TokenKey{{Id: "test", Secret: []byte("test-secret")}},
+}
+
 func TestI18n(t *testing.T) {
 	i18n, err := hypersonic.NewI18n("i18n")
 	if err != nil {
@@ -57,11 +63,22 @@ func TestHypersonic(t *testing.T) {
 		Listener: hypersonic.NewEchoListener(),
 		I18n:     i18n,
 		IsDev:    true,
+		Token: hypersonic.TokenConfig{
+			Storage: hypersonic.TokenStorageRedis,
+			Redis:   cache,
+		},
 	})
 	if err != nil {
 		t.Fatalf(err.Error())
 	}
 
+	h.OnStop("mysql", func(ctx context.Context) error {
+		return db.Close()
+	})
+	h.OnStop("redis", func(ctx context.Context) error {
+		return cache.Close()
+	})
+
 	h.RegisterControllers("/public", []hypersonic.Controller{
 		user_public.NewController(db, cache),
 	})
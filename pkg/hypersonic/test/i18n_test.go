@@ -0,0 +1,137 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-13 15:12:40
+ */
+
+package test
+
+import (
+	"context"
+	"framework/pkg/hypersonic"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestI18nFS(t *testing.T) {
+	i18n, err := hypersonic.NewI18nFS(fstest.MapFS{
+		"en.toml": {Data: []byte(`
+Hello = "Hello {name}"
+
+[user.login]
+title = "Sign in"
+
+[step]
+one = "Step one"
+
+[apple]
+one = "{count} apple"
+other = "{count} apples in {place}"
+`)},
+		"zh-CN.toml": {Data: []byte(`
+Hello = "你好 {name}"
+
+[apple]
+other = "{count} 个苹果"
+`)},
+		"user/ru.toml": {Data: []byte(`
+[apple]
+one = "{count} яблоко"
+few = "{count} яблока"
+many = "{count} яблок"
+`)},
+		"readme.md": {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, c := range []struct {
+		got  string
+		want string
+	}{
+		{i18n.T("en", "user.login.title"), "Sign in"},
+		{i18n.Tn("zh-CN", "Hello", hypersonic.M{"name": "sunrui"}), "你好 sunrui"},
+		{i18n.Tp("en", "apple", 1, nil), "1 apple"},
+		{i18n.Tp("en", "apple", 2, hypersonic.M{"place": "box"}), "2 apples in box"},
+		{i18n.Tp("zh-CN", "apple", 1, nil), "1 个苹果"},
+		{i18n.Tp("ru", "apple", 21, nil), "21 яблоко"},
+		{i18n.Tp("ru", "apple", 3, nil), "3 яблока"},
+		{i18n.Tp("ru", "apple", 11, nil), "11 яблок"},
+	} {
+		if c.got != c.want {
+			t.Errorf("got %s, want %s", c.got, c.want)
+		}
+	}
+
+	if langs := i18n.Langs(); !reflect.DeepEqual(langs, []hypersonic.Lang{"en", "ru", "zh-CN"}) {
+		t.Errorf("unexpected langs %v", langs)
+	}
+
+	// 缺少的键
+	missing := i18n.Missing()
+	if !reflect.DeepEqual(missing["zh-CN"], []string{"step.one", "user.login.title"}) ||
+		!reflect.DeepEqual(missing["ru"], []string{"Hello", "apple.other", "step.one", "user.login.title"}) ||
+		len(missing["en"]) != 0 {
+		t.Errorf("unexpected missing %v", missing)
+	}
+
+	if i18n.Check() == nil {
+		t.Errorf("check should report missing keys")
+	}
+
+	// 并发查找不存在的键
+	waitGroup := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			_ = i18n.T("en", "NotExists")
+		}()
+	}
+	waitGroup.Wait()
+
+	if missing = i18n.Missing(); len(missing["zh-CN"]) != 2 {
+		t.Errorf("lookup should not mutate dict %v", missing)
+	}
+}
+
+func TestI18nWatch(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "en.toml")
+
+	if err := os.WriteFile(file, []byte(`Title = "v1"`), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	i18n, err := hypersonic.NewI18n(dir)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go i18n.Watch(ctx, 10*time.Millisecond)
+
+	time.Sleep(20 * time.Millisecond)
+	if err = os.WriteFile(file, []byte(`Title = "v2, updated"`), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for i := 0; i < 100 && i18n.T("en", "Title") != "v2, updated"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if title := i18n.T("en", "Title"); title != "v2, updated" {
+		t.Errorf("unexpected title %s", title)
+	}
+
+	if i18n.Check() != nil {
+		t.Errorf("single language should have no missing keys")
+	}
+}
@@ -0,0 +1,95 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-12 14:08:31
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLang(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+		Lang: hypersonic.LangConfig{
+			Fallback: map[hypersonic.Lang][]hypersonic.Lang{
+				"ja": {"ko", hypersonic.LangZhCN},
+			},
+			ProfileFunc: func(req *hypersonic.Request) hypersonic.Lang {
+				if lang := req.GetHeader("X-Profile-Lang"); lang != nil {
+					return hypersonic.Lang(*lang)
+				}
+
+				return ""
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/lang",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.GetLang(), nil), nil
+			},
+		}},
+	}})
+
+	for _, c := range []struct {
+		query  string
+		header map[string]string
+		lang   hypersonic.Lang
+	}{
+		{"", nil, hypersonic.LangEn},
+		{"", map[string]string{"Accept-Language": "fr, zh-CN;q=0.8, en;q=0.5"}, hypersonic.LangZhCN},
+		{"", map[string]string{"Accept-Language": "zh-CN;q=0, en-GB"}, hypersonic.LangEn},
+		{"", map[string]string{"Accept-Language": "zh-Hant-TW"}, hypersonic.LangZhCN},
+		{"", map[string]string{"Accept-Language": "ja"}, hypersonic.LangZhCN},
+		{"", map[string]string{"Accept-Language": "ja-JP"}, hypersonic.LangZhCN},
+		{"", map[string]string{"Accept-Language": "en;q=0, *"}, hypersonic.LangZhCN},
+		{"", map[string]string{"Accept-Language": "zh;q=0, fr-CA, *;q=0.5"}, hypersonic.LangEn},
+		{"", map[string]string{"Accept-Language": "fr, *;q=0.1"}, hypersonic.LangEn},
+		{"?lang=zh-cn", map[string]string{"Accept-Language": "en"}, hypersonic.LangZhCN},
+		{"", map[string]string{"Accept-Language": "en", "Cookie": "lang=zh-CN"}, hypersonic.LangZhCN},
+		{"?lang=fr", map[string]string{"Accept-Language": "en", "X-Profile-Lang": "zh-CN"}, hypersonic.LangZhCN},
+		{"?lang=en", map[string]string{"X-Profile-Lang": "zh-CN"}, hypersonic.LangEn},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/public/lang"+c.query, nil)
+		for key, value := range c.header {
+			r.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if want := `{"data":"` + string(c.lang) + `"}`; w.Body.String() != want {
+			t.Errorf("%s %v got %s, want %s", c.query, c.header, w.Body.String(), want)
+		}
+	}
+
+	if langs := i18n.Langs(); len(langs) != 2 || langs[0] != hypersonic.LangEn || langs[1] != hypersonic.LangZhCN {
+		t.Errorf("unexpected langs %v", langs)
+	}
+
+	// 返回副本，修改不影响协商
+	i18n.Langs()[0] = "fr"
+	if langs := i18n.Langs(); langs[0] != hypersonic.LangEn {
+		t.Errorf("langs should be copied, got %v", langs)
+	}
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-22 22:03:51
+ */
+
+package test
+
+import (
+	"context"
+	"errors"
+	"framework/pkg/hypersonic"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 地址适配器，记录监听地址
+type addrListener struct {
+	hypersonic.EchoListener
+	addr chan string // 监听地址
+}
+
+// OnStart 启动
+func (addrListener addrListener) OnStart(addr string) {
+	_, port, _ := net.SplitHostPort(addr)
+	addrListener.addr <- "127.0.0.1:" + port
+}
+
+func TestLifecycle(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	listener := addrListener{EchoListener: hypersonic.NewEchoListener(), addr: make(chan string, 1)}
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: listener,
+		I18n:     i18n,
+		Token:    testToken,
+		Server: hypersonic.ServerConfig{
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/slow",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				time.Sleep(200 * time.Millisecond)
+				return hypersonic.NewData("done", nil), nil
+			},
+		}},
+	}})
+
+	events := make([]string, 0)
+
+	for _, name := range []string{"mysql", "redis", "amqp"} {
+		h.OnStart(name, func(ctx context.Context) error {
+			events = append(events, "start "+name)
+			return nil
+		})
+		h.OnStop(name, func(ctx context.Context) error {
+			events = append(events, "stop "+name)
+			return nil
+		})
+	}
+
+	// 随机端口
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- h.Run(0)
+	}()
+	addr := <-listener.addr
+
+	var resp *http.Response
+	responded := make(chan struct{})
+	go func() {
+		defer close(responded)
+		resp, _ = http.Get("http://" + addr + "/public/slow")
+	}()
+
+	// 请求处理中关闭
+	time.Sleep(100 * time.Millisecond)
+	if err = h.Shutdown(context.Background()); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	<-responded
+	if resp == nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("in-flight request not drained")
+	}
+
+	if err = <-runErr; err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if !reflect.DeepEqual(events, []string{
+		"start mysql", "start redis", "start amqp", "stop amqp", "stop redis", "stop mysql",
+	}) {
+		t.Fatalf("events %v", events)
+	}
+}
+
+func TestLifecycleStartError(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	events := make([]string, 0)
+	errStart := errors.New("amqp unavailable")
+
+	for _, name := range []string{"mysql", "redis", "amqp", "cron"} {
+		h.OnStart(name, func(ctx context.Context) error {
+			if name == "amqp" {
+				return errStart
+			}
+
+			events = append(events, "start "+name)
+			return nil
+		})
+		h.OnStop(name, func(ctx context.Context) error {
+			events = append(events, "stop "+name)
+			return nil
+		})
+	}
+
+	// 启动失败时逆序停止已启动的回调
+	if err = h.Run(0); !errors.Is(err, errStart) {
+		t.Fatalf("unexpected err %v", err)
+	}
+
+	if !reflect.DeepEqual(events, []string{
+		"start mysql", "start redis", "stop redis", "stop mysql",
+	}) {
+		t.Fatalf("events %v", events)
+	}
+}
@@ -0,0 +1,351 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-30 10:25:17
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"framework/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLimit(t *testing.T) {
+	server := miniredis.RunT(t)
+	port, _ := strconv.Atoi(server.Port())
+
+	cache, err := redis.New(redis.Config{
+		Host: server.Host(),
+		Port: port,
+	}, 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// 推进时间
+	now := time.Now()
+	server.SetTime(now)
+	advance := func(d time.Duration) {
+		now = now.Add(d)
+		server.SetTime(now)
+		server.FastForward(d)
+	}
+
+	newLimit := func(algorithm hypersonic.LimitAlgorithm) hypersonic.Limit {
+		return hypersonic.NewLimit(cache, hypersonic.LimitConfig{
+			LimitType: hypersonic.LimitName,
+			Algorithm: algorithm,
+			MaxTimes:  2,
+			Interval:  10 * time.Second,
+		})
+	}
+
+	// 固定窗口
+	fixed := newLimit(hypersonic.LimitFixedWindow)
+	if !fixed.Add("a") || fixed.Left("a") != 1 || fixed.RetryAfter("a") != 0 {
+		t.Fatalf("fixed first left %d", fixed.Left("a"))
+	}
+	if !fixed.Add("a") || fixed.Add("a") || fixed.Left("a") != 0 || fixed.RetryAfter("a") != 10*time.Second {
+		t.Fatalf("fixed exceeded left %d, retry %s", fixed.Left("a"), fixed.RetryAfter("a"))
+	}
+
+	// 没有过期时间的旧键视为新窗口
+	server.Set("Limit:Name:b", "100")
+	if !fixed.Add("b") || server.TTL("Limit:Name:b") != 10*time.Second {
+		t.Fatalf("fixed legacy key ttl %s", server.TTL("Limit:Name:b"))
+	}
+
+	// 滑动窗口
+	sliding := newLimit(hypersonic.LimitSlidingWindow)
+	if !sliding.Add("a") {
+		t.Fatalf("sliding first")
+	}
+	advance(6 * time.Second)
+	if !sliding.Add("a") || sliding.Add("a") || sliding.RetryAfter("a") != 4*time.Second {
+		t.Fatalf("sliding exceeded retry %s", sliding.RetryAfter("a"))
+	}
+	advance(4 * time.Second)
+	if sliding.Left("a") != 1 || !sliding.Add("a") || sliding.Add("a") {
+		t.Fatalf("sliding slide left %d", sliding.Left("a"))
+	}
+
+	// 令牌桶
+	bucket := newLimit(hypersonic.LimitTokenBucket)
+	if !bucket.Add("a") || !bucket.Add("a") || bucket.Add("a") || bucket.RetryAfter("a") != 5*time.Second {
+		t.Fatalf("bucket exceeded retry %s", bucket.RetryAfter("a"))
+	}
+	advance(5 * time.Second)
+	if bucket.Left("a") != 1 || !bucket.Add("a") || bucket.Add("a") {
+		t.Fatalf("bucket refill left %d", bucket.Left("a"))
+	}
+
+	bucket.Reset("a")
+	if bucket.Left("a") != 2 {
+		t.Fatalf("bucket reset left %d", bucket.Left("a"))
+	}
+}
+
+// 记录限制信息的监听者
+type limitListener struct {
+	hypersonic.EchoListener
+	infos *[]hypersonic.LimitInfo // 限制信息
+}
+
+// OnLimit 限制调用
+func (limitListener limitListener) OnLimit(req *hypersonic.Request, info hypersonic.LimitInfo) {
+	*limitListener.infos = append(*limitListener.infos, info)
+}
+
+func TestLimitHeader(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	infos := make([]hypersonic.LimitInfo, 0)
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: limitListener{EchoListener: hypersonic.NewEchoListener(), infos: &infos},
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/limit",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/ping",
+			Limits: []hypersonic.Limit{
+				hypersonic.NewLimit(newMiniRedis(t), hypersonic.LimitConfig{
+					LimitType: hypersonic.LimitIp,
+					MaxTimes:  2,
+					Interval:  time.Minute,
+				}),
+			},
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData("pong", nil), nil
+			},
+		}},
+	}})
+
+	// 请求
+	ping := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public/limit/ping", nil))
+		return w
+	}
+
+	w := ping()
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != "1" ||
+		w.Header().Get("RateLimit-Reset") != "60" || w.Header().Get("Retry-After") != "" {
+		t.Fatalf("first status %d, header %v", w.Code, w.Header())
+	}
+
+	ping()
+	if w = ping(); w.Code != http.StatusTooManyRequests || w.Header().Get("RateLimit-Remaining") != "0" ||
+		w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("limited status %d, header %v", w.Code, w.Header())
+	}
+
+	if len(infos) != 1 || infos[0].LimitType != hypersonic.LimitIp || infos[0].RetryAfter != time.Minute {
+		t.Fatalf("listener infos %+v", infos)
+	}
+}
+
+type limitKeyParameter struct {
+	Phone string `json:"phone" validate:"required"` // 手机号
+}
+
+func TestLimitKey(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	cache := newMiniRedis(t)
+	newLimit := func(limitType hypersonic.LimitType, keyFunc hypersonic.LimitKeyFunc) hypersonic.Limit {
+		return hypersonic.NewLimit(cache, hypersonic.LimitConfig{
+			LimitType: limitType,
+			MaxTimes:  1,
+			Interval:  time.Minute,
+			KeyFunc:   keyFunc,
+		})
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/limit",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/sms",
+			Limits: []hypersonic.Limit{
+				newLimit(hypersonic.LimitPhone, hypersonic.LimitKeys(hypersonic.LimitKeyIp(), hypersonic.LimitKeyField("phone"))),
+			},
+			Handler: hypersonic.Handle(func(req *hypersonic.Request, p *limitKeyParameter) (*string, *hypersonic.Error) {
+				return &p.Phone, nil
+			}),
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/order/:id",
+			Limits: []hypersonic.Limit{
+				newLimit("Order", hypersonic.LimitKeys(hypersonic.LimitKeyParam("id"), hypersonic.LimitKeyHeader("X-Device-Id"))),
+			},
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.GetParam("id"), nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/name",
+			Limits:       []hypersonic.Limit{newLimit(hypersonic.LimitName, nil)},
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(nil, nil), nil
+			},
+		}},
+	}})
+
+	// 请求
+	call := func(method string, path string, body string, device string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/public/limit"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Device-Id", device)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := call(http.MethodPost, "/sms", `{"phone":"13800000000"}`, ""); w.Code != http.StatusOK || w.Body.String() != `{"data":"13800000000"}` {
+		t.Fatalf("sms status %d, body %s", w.Code, w.Body.String())
+	}
+	if w := call(http.MethodPost, "/sms", `{"phone":"13800000000"}`, ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("sms same phone status %d", w.Code)
+	}
+	if w := call(http.MethodPost, "/sms", `{"phone":"13900000000"}`, ""); w.Code != http.StatusOK {
+		t.Fatalf("sms other phone status %d", w.Code)
+	}
+	if w := call(http.MethodPost, "/sms", `{}`, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("sms without phone status %d", w.Code)
+	}
+
+	call(http.MethodGet, "/order/1", "", "a")
+	if w := call(http.MethodGet, "/order/1", "", "a"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("order same key status %d", w.Code)
+	}
+	if w := call(http.MethodGet, "/order/1", "", "b"); w.Code != http.StatusOK {
+		t.Fatalf("order other device status %d", w.Code)
+	}
+	if w := call(http.MethodGet, "/order/2", "", "a"); w.Code != http.StatusOK {
+		t.Fatalf("order other id status %d", w.Code)
+	}
+
+	if w := call(http.MethodGet, "/name", "", ""); w.Code != http.StatusInternalServerError {
+		t.Fatalf("name without key func status %d", w.Code)
+	}
+}
+
+func TestLimitErrorCodes(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/limit",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/login",
+			Limits: []hypersonic.Limit{
+				hypersonic.NewLimit(newMiniRedis(t), hypersonic.LimitConfig{
+					LimitType:  hypersonic.LimitName,
+					MaxTimes:   1,
+					Interval:   time.Minute,
+					KeyFunc:    hypersonic.LimitKeyHeader("X-Name"),
+					ErrorCodes: []hypersonic.Code{"PasswordNotMatch"},
+					Code:       "LoginRateLimit",
+				}),
+			},
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				if password := req.GetHeader("X-Password"); password == nil || *password != "right" {
+					return nil, hypersonic.NewError("PasswordNotMatch")
+				}
+
+				return hypersonic.NewData(nil, nil), nil
+			},
+		}},
+	}})
+
+	// 登录
+	login := func(password string) hypersonic.Code {
+		r := httptest.NewRequest(http.MethodPost, "/public/limit/login", nil)
+		r.Header.Set("X-Name", "sunrui")
+		r.Header.Set("X-Password", password)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		var reply hypersonic.Error
+		_ = json.Unmarshal(w.Body.Bytes(), &reply)
+		return reply.Code
+	}
+
+	// 成功登录不计数
+	for i := 0; i < 2; i++ {
+		if code := login("right"); code != "" {
+			t.Fatalf("login %d should pass, got %s", i, code)
+		}
+	}
+
+	if code := login("wrong"); code != "PasswordNotMatch" {
+		t.Fatalf("unexpected code %s", code)
+	}
+
+	if code := login("right"); code != "LoginRateLimit" {
+		t.Fatalf("unexpected code %s", code)
+	}
+}
+
+func TestLimitConfig(t *testing.T) {
+	for _, config := range []hypersonic.LimitConfig{
+		{LimitType: hypersonic.LimitIp, Algorithm: hypersonic.LimitTokenBucket, MaxTimes: 0, Interval: time.Minute},
+		{LimitType: hypersonic.LimitIp, Algorithm: hypersonic.LimitTokenBucket, MaxTimes: 1, Interval: 0},
+		{LimitType: hypersonic.LimitIp, MaxTimes: 1, Interval: time.Microsecond},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%+v should panic", config)
+				}
+			}()
+
+			hypersonic.NewLimit(nil, config)
+		}()
+	}
+}
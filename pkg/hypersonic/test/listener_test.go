@@ -0,0 +1,127 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-07 09:46:15
+ */
+
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// 记录事件的监听者
+type eventListener struct {
+	hypersonic.NopListener
+
+	mutex  sync.Mutex // 锁
+	events []string   // 事件
+	stack  []byte     // 异常堆栈
+}
+
+// 记录
+func (eventListener *eventListener) add(event string) {
+	eventListener.mutex.Lock()
+	defer eventListener.mutex.Unlock()
+
+	eventListener.events = append(eventListener.events, event)
+}
+
+// OnPanic 异常
+func (eventListener *eventListener) OnPanic(req *hypersonic.Request, recovered any, stack []byte) {
+	eventListener.stack = stack
+	eventListener.add("panic")
+}
+
+// OnAuthFailure 鉴权失败
+func (eventListener *eventListener) OnAuthFailure(req *hypersonic.Request, err *hypersonic.Error) {
+	eventListener.add("auth " + string(err.Code))
+}
+
+// OnNotFound 未找到
+func (eventListener *eventListener) OnNotFound(req *hypersonic.Request) {
+	eventListener.add("not found")
+}
+
+// OnStop 停止
+func (eventListener *eventListener) OnStop(err error) {
+	eventListener.add("stop")
+}
+
+func TestListener(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, isDev := range []bool{false, true} {
+		listener := &eventListener{}
+
+		h, err := hypersonic.New(hypersonic.Config{
+			Listener: hypersonic.NewMultiListener(hypersonic.NewEchoListener(), listener),
+			I18n:     i18n,
+			Token:    testToken,
+			IsDev:    isDev,
+		})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		h.RegisterControllers("/public", []hypersonic.Controller{{
+			Path: "/listener",
+			Routers: []hypersonic.Router{{
+				HttpMethod:   http.MethodGet,
+				RelativePath: "/panic",
+				InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+					panic("boom")
+				},
+			}, {
+				HttpMethod:   http.MethodGet,
+				RelativePath: "/token",
+				InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+					return hypersonic.NewData(req.Token.MustGetUserId(), nil), nil
+				},
+			}},
+		}})
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public/listener/panic", nil))
+
+		var reply hypersonic.Error
+		if err = json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if reply.Code != hypersonic.CodeInternalError {
+			t.Fatalf("unexpected code %s", reply.Code)
+		}
+
+		// 发布模式下异常与堆栈不响应给客户端
+		if hasPanic := len(reply.Argv) > 1 && strings.Contains(w.Body.String(), "boom"); hasPanic != isDev {
+			t.Errorf("isDev %v, reply %s", isDev, w.Body.String())
+		}
+
+		if !strings.Contains(string(listener.stack), "listener_test.go") {
+			t.Errorf("stack should be complete: %s", listener.stack)
+		}
+
+		for _, path := range []string{"/public/listener/token", "/public/listener/missing"} {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		}
+
+		if err = h.Shutdown(context.Background()); err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if strings.Join(listener.events, ",") != "panic,auth NoAuth,not found,stop" {
+			t.Errorf("unexpected events %v", listener.events)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-05 15:02:26
+ */
+
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	buffer := bytes.Buffer{}
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewLogListener(hypersonic.LogConfig{
+			Handler:      slog.NewJSONHandler(&buffer, nil),
+			RedactFields: []string{"password", "card.number"},
+			MaxBodySize:  110,
+		}),
+		I18n:  i18n,
+		Token: testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// 路由
+	router := func(relativePath string, data *hypersonic.Data, err *hypersonic.Error, sleep time.Duration) hypersonic.Router {
+		return hypersonic.Router{
+			HttpMethod:   http.MethodPost,
+			RelativePath: relativePath,
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				time.Sleep(sleep)
+				return data, err
+			},
+		}
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/log",
+		Routers: []hypersonic.Router{
+			router("/ok", hypersonic.NewData(map[string]any{"token": "t", "password": "p"}, nil), nil, 0),
+			router("/long", hypersonic.NewData(strings.Repeat("长", 40), nil), nil, 0),
+			router("/error", nil, hypersonic.NewError(hypersonic.CodeInternalError), 0),
+			router("/conflict", nil, hypersonic.NewError(hypersonic.CodeConflict), 0),
+		},
+	}, {
+		Path: "/quiet",
+		Log: &hypersonic.LogConfig{
+			SampleRate:    -1,
+			SlowThreshold: 20 * time.Millisecond,
+		},
+		Routers: []hypersonic.Router{
+			router("/ok", hypersonic.NewData(nil, nil), nil, 0),
+			router("/slow", hypersonic.NewData(nil, nil), nil, 30*time.Millisecond),
+		},
+	}})
+
+	// 请求并返回日志记录
+	call := func(path string, body string) map[string]any {
+		buffer.Reset()
+
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Device", "ios")
+
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if buffer.Len() == 0 {
+			return nil
+		}
+
+		record := make(map[string]any)
+		if err := json.Unmarshal(buffer.Bytes(), &record); err != nil {
+			t.Fatalf("%s: %s", err.Error(), buffer.String())
+		}
+		return record
+	}
+
+	// 首部与字段脱敏
+	record := call("/public/log/ok", `{"name":"ximei","password":"123","card":{"number":"6222","password":"456"},"items":[{"Password":"789"}]}`)
+	header := record["header"].(map[string]any)
+	if record["level"] != "INFO" || header["Authorization"] != "***" || header["X-Device"] != "ios" || record["requestId"] == "" {
+		t.Fatalf("record %v", record)
+	}
+	if record["body"] != `{"card":{"number":"***","password":"***"},"items":[{"Password":"***"}],"name":"ximei","password":"***"}` {
+		t.Fatalf("body %v", record["body"])
+	}
+	if record["response"] != `{"data":{"password":"***","token":"t"}}` {
+		t.Fatalf("response %v", record["response"])
+	}
+
+	// 截断不拆分字符
+	if response := record2String(call("/public/log/long", "")["response"]); !strings.HasSuffix(response, "...(truncated 21 bytes)") ||
+		!strings.HasPrefix(response, `{"data":"长`) {
+		t.Fatalf("long response %s", response)
+	}
+
+	// 错误级别
+	if record = call("/public/log/error", ""); record["level"] != "ERROR" || record["code"] != "InternalError" || record["status"] != float64(500) {
+		t.Fatalf("error record %v", record)
+	}
+	if record = call("/public/log/conflict", ""); record["level"] != "WARN" || record["code"] != "Conflict" {
+		t.Fatalf("conflict record %v", record)
+	}
+
+	// 控制器配置，不记录成功请求，记录慢请求
+	if record = call("/public/quiet/ok", ""); record != nil {
+		t.Fatalf("quiet record %v", record)
+	}
+	if record = call("/public/quiet/slow", ""); record["level"] != "WARN" || record["slow"] != true {
+		t.Fatalf("slow record %v", record)
+	}
+}
+
+// 转换字符串
+func record2String(value any) string {
+	s, _ := value.(string)
+	return s
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-21 11:32:40
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMethod(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	ok := func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+		return hypersonic.NewData(req.GetMethod(), nil), nil
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path:              "/order",
+		RequestMiddleware: hypersonic.TokenRequestMiddleware,
+		Routers: []hypersonic.Router{
+			{HttpMethod: http.MethodGet, RelativePath: "/:id", InvokeFunc: ok},
+			{HttpMethod: http.MethodPatch, RelativePath: "/:id", InvokeFunc: ok},
+		},
+	}, {
+		Path: "/echo",
+		Routers: []hypersonic.Router{
+			{HttpMethod: hypersonic.MethodAny, RelativePath: "", InvokeFunc: ok},
+		},
+	}, {
+		Path: "/item",
+		Routers: []hypersonic.Router{
+			{HttpMethod: http.MethodGet, RelativePath: "/:id", InvokeFunc: ok},
+		},
+	}})
+
+	// 其它控制器对同一路径显式声明 HEAD 与 OPTIONS，优先于自动响应
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/item",
+		Routers: []hypersonic.Router{
+			{HttpMethod: http.MethodHead, RelativePath: "/:id", InvokeFunc: ok},
+			{HttpMethod: http.MethodOptions, RelativePath: "/:id", InvokeFunc: ok},
+		},
+	}})
+
+	for _, method := range []string{http.MethodHead, http.MethodOptions} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/public/item/1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("explicit %s status %d", method, w.Code)
+		}
+	}
+
+	// OPTIONS 不经过控制器中间件
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/public/order/1", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, HEAD, OPTIONS, PATCH" {
+		t.Fatalf("options status %d, allow %s", w.Code, w.Header().Get("Allow"))
+	}
+
+	// HEAD 与 GET 一致，需要认证
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/public/order/1", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("head status %d", w.Code)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete, http.MethodOptions} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/public/echo", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("any %s status %d", method, w.Code)
+		}
+	}
+
+	// 开始服务后路由树只读
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("register after serving should panic")
+		}
+	}()
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/late",
+		Routers: []hypersonic.Router{
+			{HttpMethod: http.MethodGet, InvokeFunc: ok},
+		},
+	}})
+}
+
+func TestCors(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	for _, allowOrigins := range [][]string{nil, {"https://example.com"}} {
+		h, err := hypersonic.New(hypersonic.Config{
+			Listener: hypersonic.NewEchoListener(),
+			I18n:     i18n,
+			Token:    testToken,
+			Cors: hypersonic.CorsConfig{
+				AllowOrigins:     allowOrigins,
+				AllowCredentials: true,
+				MaxAge:           time.Hour,
+			},
+		})
+		if err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		h.RegisterControllers("/public", []hypersonic.Controller{{
+			Path:              "/order",
+			RequestMiddleware: hypersonic.TokenRequestMiddleware,
+			Routers: []hypersonic.Router{{
+				HttpMethod:   http.MethodPatch,
+				RelativePath: "/:id",
+				InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+					return hypersonic.NewData(nil, nil), nil
+				},
+			}},
+		}})
+
+		preflight := func(origin string) http.Header {
+			r := httptest.NewRequest(http.MethodOptions, "/public/order/1", nil)
+			r.Header.Set("Origin", origin)
+			r.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			r.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusNoContent {
+				t.Fatalf("preflight status %d", w.Code)
+			}
+
+			return w.Header()
+		}
+
+		// 未配置来源时仅响应允许的方法
+		header := preflight("https://example.com")
+		if header.Get("Access-Control-Allow-Methods") != "OPTIONS, PATCH" {
+			t.Fatalf("unexpected allow methods %s", header.Get("Access-Control-Allow-Methods"))
+		}
+
+		if allowOrigins == nil {
+			if header.Get("Access-Control-Allow-Origin") != "" || header.Get("Access-Control-Allow-Headers") != "" {
+				t.Fatalf("unexpected cors header %v", header)
+			}
+			continue
+		}
+
+		if header.Get("Access-Control-Allow-Origin") != "https://example.com" ||
+			header.Get("Access-Control-Allow-Headers") != "Authorization, Content-Type" ||
+			header.Get("Access-Control-Allow-Credentials") != "true" ||
+			header.Get("Access-Control-Max-Age") != "3600" {
+			t.Fatalf("unexpected cors header %v", header)
+		}
+
+		// 不允许的来源
+		if header = preflight("https://evil.com"); header.Get("Access-Control-Allow-Origin") != "" || header.Get("Access-Control-Allow-Headers") != "" {
+			t.Fatalf("unexpected cors header %v", header)
+		}
+
+		// 实际请求也响应允许的来源
+		r := httptest.NewRequest(http.MethodPatch, "/public/order/1", nil)
+		r.Header.Set("Origin", "https://example.com")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("Access-Control-Allow-Origin") != "https://example.com" {
+			t.Fatalf("unexpected actual response %d %v", w.Code, w.Header())
+		}
+	}
+
+	// 携带 cookie 时必须显式列出来源
+	if _, err = hypersonic.New(hypersonic.Config{
+		I18n:  i18n,
+		Token: testToken,
+		Cors:  hypersonic.CorsConfig{AllowOrigins: []string{"*"}, AllowCredentials: true},
+	}); err == nil {
+		t.Fatalf("wildcard origin with credentials accepted")
+	}
+}
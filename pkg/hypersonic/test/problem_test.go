@@ -0,0 +1,131 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-08 14:36:20
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 问题参数
+type problemParameter struct {
+	Name string `json:"name" validate:"required"` // 名称
+}
+
+func TestProblem(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+		Problem: hypersonic.ProblemConfig{
+			Format:   hypersonic.ErrorFormatNegotiate,
+			TypeBase: "https://example.com/problems/",
+		},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/problem",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "",
+			Handler: hypersonic.Handle(func(req *hypersonic.Request, p *problemParameter) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(p.Name, nil), nil
+			}),
+		}},
+	}})
+
+	request := func(accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/public/problem?from=test", strings.NewReader(`{}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept-Language", "zh-CN")
+		r.Header.Set("X-Request-ID", "problem-1")
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	// 未协商时保持原格式
+	w := request("")
+	if contentType := w.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+		t.Fatalf("unexpected content type %s", contentType)
+	}
+
+	var reply hypersonic.Error
+	if err = json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if reply.Code != hypersonic.CodeParameterError || len(reply.Errors) != 1 {
+		t.Fatalf("unexpected reply %s", w.Body.String())
+	}
+
+	if vary := w.Header().Values("Vary"); !slices.Contains(vary, "Accept") {
+		t.Fatalf("negotiated reply should vary by accept, got %v", vary)
+	}
+
+	// 权重为 0 或低于 application/json 时不以 RFC 7807 响应
+	for _, accept := range []string{
+		hypersonic.MIMEProblemJSON + ";q=0",
+		hypersonic.MIMEProblemJSON + ";q=0.5, application/json",
+		"*/*",
+	} {
+		if contentType := request(accept).Header().Get("Content-Type"); !strings.HasPrefix(contentType, "application/json") {
+			t.Fatalf("accept %s unexpected content type %s", accept, contentType)
+		}
+	}
+
+	w = request(hypersonic.MIMEProblemJSON + ", application/json")
+	if contentType := w.Header().Get("Content-Type"); contentType != hypersonic.MIMEProblemJSON {
+		t.Fatalf("unexpected content type %s", contentType)
+	}
+
+	var problem hypersonic.Problem
+	if err = json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if problem.Type != "https://example.com/problems/ParameterError" ||
+		problem.Title != "参数错误" ||
+		problem.Status != http.StatusBadRequest ||
+		problem.Instance != "/public/problem?from=test" ||
+		problem.RequestId != "problem-1" ||
+		problem.Detail == "" ||
+		len(problem.Errors) != 1 {
+		t.Errorf("unexpected problem %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/public/missing", nil)
+	r.Header.Set("Accept", hypersonic.MIMEProblemJSON)
+	h.ServeHTTP(w, r)
+
+	problem = hypersonic.Problem{}
+	if err = json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if problem.Status != http.StatusNotFound || problem.Code != hypersonic.CodeNotFound || problem.Errors != nil {
+		t.Errorf("unexpected problem %s", w.Body.String())
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-31 11:18:40
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 创建全局限流测试服务
+func newRateLimitHypersonic(t *testing.T, config hypersonic.Config) *hypersonic.Hypersonic {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	config.Listener = hypersonic.NewEchoListener()
+	config.I18n = i18n
+	config.Token = testToken
+
+	h, err := hypersonic.New(config)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/ip",
+		Routers: []hypersonic.Router{{
+			HttpMethod: http.MethodGet,
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.GetIp(), nil), nil
+			},
+		}},
+	}})
+
+	return h
+}
+
+// 记录是否读取的请求体
+type readFlag struct {
+	io.Reader
+	read bool // 是否已读取
+}
+
+// Read 读取
+func (readFlag *readFlag) Read(p []byte) (int, error) {
+	readFlag.read = true
+	return readFlag.Reader.Read(p)
+}
+
+// 请求 ip，remoteAddr 为直连地址
+func getIp(h *hypersonic.Hypersonic, remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/public/ip", nil)
+	req.RemoteAddr = remoteAddr + ":1234"
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	// 可信代理
+	h := newRateLimitHypersonic(t, hypersonic.Config{
+		TrustedProxies: []string{"10.0.0.0/8"},
+	})
+
+	if w := getIp(h, "10.0.0.1", "203.0.113.9"); w.Body.String() != `{"data":"203.0.113.9"}` {
+		t.Fatalf("trusted proxy body %s", w.Body.String())
+	}
+	if w := getIp(h, "198.51.100.1", "203.0.113.9"); w.Body.String() != `{"data":"198.51.100.1"}` {
+		t.Fatalf("untrusted proxy body %s", w.Body.String())
+	}
+
+	// 内存限流与名单
+	h = newRateLimitHypersonic(t, hypersonic.Config{
+		RateLimit: hypersonic.RateLimitConfig{
+			Rate:     0.001,
+			Burst:    2,
+			AllowIps: []string{"192.168.1.0/24"},
+			DenyIps:  []string{"198.51.100.7"},
+		},
+	})
+
+	if w := getIp(h, "198.51.100.1", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" {
+		t.Fatalf("first status %d, header %v", w.Code, w.Header())
+	}
+	getIp(h, "198.51.100.1", "")
+	if w := getIp(h, "198.51.100.1", ""); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("limited status %d, header %v", w.Code, w.Header())
+	}
+
+	// 伪造的 X-Forwarded-For 不能绕过限流
+	if w := getIp(h, "198.51.100.1", "203.0.113.10"); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed status %d", w.Code)
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := getIp(h, "192.168.1.20", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("allow status %d, header %v", w.Code, w.Header())
+		}
+	}
+
+	if w := getIp(h, "198.51.100.7", ""); w.Code != http.StatusForbidden {
+		t.Fatalf("deny status %d", w.Code)
+	}
+
+	// 禁止的请求不读取请求体
+	body := &readFlag{Reader: strings.NewReader(`{"name":"sunrui"}`)}
+	req := httptest.NewRequest(http.MethodGet, "/public/ip", body)
+	req.RemoteAddr = "198.51.100.7:1234"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || body.read {
+		t.Fatalf("deny status %d, body read %t", w.Code, body.read)
+	}
+
+	// redis 限流多实例共享
+	cache := newMiniRedis(t)
+	config := hypersonic.Config{
+		RateLimit: hypersonic.RateLimitConfig{
+			Rate:  0.001,
+			Burst: 2,
+			Redis: cache,
+		},
+	}
+
+	a := newRateLimitHypersonic(t, config)
+	b := newRateLimitHypersonic(t, config)
+
+	getIp(a, "198.51.100.1", "")
+	if w := getIp(b, "198.51.100.1", ""); w.Code != http.StatusOK || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("shared status %d, header %v", w.Code, w.Header())
+	}
+	if w := getIp(a, "198.51.100.1", ""); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("shared limited status %d", w.Code)
+	}
+
+	// 非法配置
+	if _, err := hypersonic.New(hypersonic.Config{Token: testToken, RateLimit: hypersonic.RateLimitConfig{DenyIps: []string{"bad"}}}); err == nil {
+		t.Fatalf("invalid deny ip accepted")
+	}
+}
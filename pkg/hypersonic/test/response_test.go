@@ -0,0 +1,118 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-27 16:42:09
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err = os.WriteFile(path, []byte("id,name\n1,ximei\n"), 0644); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// 路由
+	router := func(relativePath string, data *hypersonic.Data, err *hypersonic.Error) hypersonic.Router {
+		return hypersonic.Router{
+			HttpMethod:   http.MethodGet,
+			RelativePath: relativePath,
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return data, err
+			},
+		}
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/response",
+		Routers: []hypersonic.Router{
+			router("/json", hypersonic.NewData("ximei", nil), nil),
+			router("/bytes", hypersonic.NewBytes("image/png", []byte("png")), nil),
+			router("/file", hypersonic.NewFile(path), nil),
+			router("/attachment", hypersonic.NewAttachment(path, "导出.csv"), nil),
+			router("/missing", hypersonic.NewFile(path+".missing"), nil),
+			router("/stream", hypersonic.NewStream("text/plain", strings.NewReader("stream")), nil),
+			router("/redirect", hypersonic.NewRedirect(http.StatusFound, "/public/response/json"), nil),
+			router("/empty", hypersonic.NewNoContent(), nil),
+			router("/no-content", nil, hypersonic.NewError(hypersonic.CodeNoContent)),
+		},
+	}})
+
+	// 请求
+	get := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public/response"+path, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, c := range []struct {
+		path        string
+		header      map[string]string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/json", nil, http.StatusOK, "application/json; charset=utf-8", `{"data":"ximei"}`},
+		{"/bytes", nil, http.StatusOK, "image/png", "png"},
+		{"/file", nil, http.StatusOK, "text/csv; charset=utf-8", "id,name\n1,ximei\n"},
+		{"/file", map[string]string{"Range": "bytes=8-"}, http.StatusPartialContent, "text/csv; charset=utf-8", "1,ximei\n"},
+		{"/missing", nil, http.StatusNotFound, "application/json; charset=utf-8", ""},
+		{"/stream", nil, http.StatusOK, "text/plain", "stream"},
+		{"/redirect", nil, http.StatusFound, "", ""},
+		{"/empty", nil, http.StatusNoContent, "", ""},
+		{"/no-content", nil, http.StatusNoContent, "", ""},
+	} {
+		w := get(c.path, c.header)
+
+		if w.Code != c.status {
+			t.Fatalf("%s status %d, body %s", c.path, w.Code, w.Body.String())
+		}
+		if c.contentType != "" && w.Header().Get("Content-Type") != c.contentType {
+			t.Fatalf("%s content type %s", c.path, w.Header().Get("Content-Type"))
+		}
+		if c.body != "" && w.Body.String() != c.body {
+			t.Fatalf("%s body %s", c.path, w.Body.String())
+		}
+	}
+
+	if w := get("/redirect", nil); w.Header().Get("Location") != "/public/response/json" {
+		t.Fatalf("redirect location %s", w.Header().Get("Location"))
+	}
+
+	if w := get("/attachment", nil); !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment; filename*=utf-8''") {
+		t.Fatalf("attachment disposition %s", w.Header().Get("Content-Disposition"))
+	}
+
+	if w := get("/no-content", nil); w.Body.Len() != 0 {
+		t.Fatalf("no content body %s", w.Body.String())
+	}
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-17 23:40:12
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"framework/pkg/mysql"
+	"framework/pkg/redis"
+	"github.com/alicebob/miniredis/v2"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// 创建内存 redis
+func newMiniRedis(t *testing.T) *redis.Redis {
+	server := miniredis.RunT(t)
+	port, _ := strconv.Atoi(server.Port())
+
+	cache, err := redis.New(redis.Config{
+		Host: server.Host(),
+		Port: port,
+	}, 0)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	return cache
+}
+
+func TestSession(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token: hypersonic.TokenConfig{
+			Keys:  testToken.Keys,
+			Redis: newMiniRedis(t),
+		},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/session",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/login",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.Token.Login("user-id", mysql.ModelRefer{
+					DeviceType: mysql.DeviceWeb,
+				}), nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/refresh",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.Token.Refresh(), nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/me",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.Token.MustGetUserId(), nil), nil
+			},
+		}},
+	}})
+
+	// 调用并解析令牌对
+	call := func(method string, path string, token string) (int, hypersonic.TokenPair) {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		var result struct {
+			Data hypersonic.TokenPair `json:"data"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &result)
+
+		return w.Code, result.Data
+	}
+
+	_, pair := call(http.MethodPost, "/public/session/login", "")
+	if code, _ := call(http.MethodGet, "/public/session/me", pair.AccessToken); code != http.StatusOK {
+		t.Fatalf("access token status %d", code)
+	}
+
+	code, refreshed := call(http.MethodPost, "/public/session/refresh", pair.RefreshToken)
+	if code != http.StatusOK || refreshed.RefreshToken == pair.RefreshToken {
+		t.Fatalf("refresh status %d", code)
+	}
+
+	if code, _ = call(http.MethodPost, "/public/session/refresh", pair.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("reused refresh token status %d", code)
+	}
+
+	sessions := h.GetSessions("user-id")
+	if len(sessions) != 1 || string(sessions[0].Device) == "" {
+		t.Fatalf("sessions %+v", sessions)
+	}
+
+	if !h.RevokeSession("user-id", sessions[0].Id) {
+		t.Fatalf("revoke session failed")
+	}
+
+	if code, _ = call(http.MethodGet, "/public/session/me", refreshed.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("revoked access token status %d", code)
+	}
+
+	_, pair = call(http.MethodPost, "/public/session/login", "")
+	h.RevokeAllSessions("user-id")
+
+	if code, _ = call(http.MethodGet, "/public/session/me", pair.AccessToken); code != http.StatusUnauthorized {
+		t.Fatalf("access token after revoke all status %d", code)
+	}
+
+	if code, _ = call(http.MethodPost, "/public/session/refresh", pair.RefreshToken); code != http.StatusUnauthorized {
+		t.Fatalf("refresh token after revoke all status %d", code)
+	}
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-15 10:20:05
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	const codeUserLocked hypersonic.Code = "UserLocked"
+	h.SetStatus(codeUserLocked, http.StatusLocked)
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/status",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/no-auth",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return nil, hypersonic.NewError(hypersonic.CodeNoAuth)
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/locked",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return nil, hypersonic.NewError(codeUserLocked)
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/panic",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				panic("panic")
+			},
+		}},
+	}})
+
+	for path, status := range map[string]int{
+		"/public/status/no-auth": http.StatusUnauthorized,
+		"/public/status/locked":  http.StatusLocked,
+		"/public/status/panic":   http.StatusInternalServerError,
+		"/public/not-found":      http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != status {
+			t.Errorf("%s status %d, want %d", path, w.Code, status)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/public/status/locked", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("method not allowed status %d", w.Code)
+	}
+}
@@ -0,0 +1,143 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-02 15:27:40
+ */
+
+package test
+
+import (
+	"framework/pkg/hypersonic"
+	"framework/pkg/telemetry"
+	"framework/pkg/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTelemetry(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// 内存导出
+	exporter := tracetest.NewInMemoryExporter()
+	tel := telemetry.New(telemetry.Config{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		Registry:       prometheus.NewRegistry(),
+	})
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener:    hypersonic.NewEchoListener(),
+		I18n:        i18n,
+		Token:       testToken,
+		Telemetry:   tel,
+		MetricsPath: "/metrics",
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	cache := newMiniRedis(t)
+	cache.UseTelemetry(tel)
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/telemetry",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/user/:id",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				cache.WithContext(req.GetContext()).Set("user", []byte(req.GetParam("id")), time.Minute)
+				return hypersonic.NewData(req.GetTrace().Traceparent(), nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/limit",
+			Limits: []hypersonic.Limit{hypersonic.NewLimit(cache, hypersonic.LimitConfig{
+				LimitType: hypersonic.LimitIp,
+				MaxTimes:  1,
+				Interval:  time.Minute,
+			})},
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(nil, nil), nil
+			},
+		}},
+	}})
+
+	// 请求
+	call := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// 路由 span 以上游为父，redis span 以路由 span 为父
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	w := call("/public/telemetry/user/1", map[string]string{
+		trace.TraceparentHeader: "00-" + traceId + "-00f067aa0ba902b7-01",
+	})
+	if w.Code != http.StatusOK || w.Header().Get(trace.TraceparentHeader) != strings.Trim(w.Body.String()[8:], `"}`) {
+		t.Fatalf("status %d, body %s, traceparent %s", w.Code, w.Body.String(), w.Header().Get(trace.TraceparentHeader))
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("spans %d", len(spans))
+	}
+
+	routeSpan := spans[len(spans)-1]
+	if routeSpan.Name != "GET /public/telemetry/user/:id" || routeSpan.SpanContext.TraceID().String() != traceId ||
+		routeSpan.Parent.SpanID().String() != "00f067aa0ba902b7" ||
+		!strings.Contains(w.Header().Get(trace.TraceparentHeader), routeSpan.SpanContext.SpanID().String()) {
+		t.Fatalf("route span %s, trace %s, parent %s", routeSpan.Name, routeSpan.SpanContext.TraceID(), routeSpan.Parent.SpanID())
+	}
+
+	redisSpan := spans[0]
+	if redisSpan.Name != "redis set" || redisSpan.Parent.SpanID() != routeSpan.SpanContext.SpanID() {
+		t.Fatalf("redis span %s, parent %s", redisSpan.Name, redisSpan.Parent.SpanID())
+	}
+
+	// 错误码记录到 span
+	exporter.Reset()
+	call("/public/telemetry/limit", nil)
+	call("/public/telemetry/limit", nil)
+	call("/public/telemetry/missing", nil)
+
+	codes := make([]string, 0)
+	for _, span := range exporter.GetSpans() {
+		for _, attr := range span.Attributes {
+			if attr.Key == attribute.Key("hypersonic.code") {
+				codes = append(codes, span.Name+" "+attr.Value.AsString())
+			}
+		}
+	}
+	if strings.Join(codes, ",") != "GET /public/telemetry/limit OK,GET /public/telemetry/limit RateLimit,GET  NotFound" {
+		t.Fatalf("codes %v", codes)
+	}
+
+	// 指标
+	metrics := call("/metrics", nil).Body.String()
+	for _, expected := range []string{
+		`hypersonic_request_duration_seconds_count{code="OK",method="GET",route="/public/telemetry/user/:id"} 1`,
+		`hypersonic_request_duration_seconds_count{code="RateLimit",method="GET",route="/public/telemetry/limit"} 1`,
+		`hypersonic_request_duration_seconds_count{code="NotFound",method="GET",route=""} 1`,
+		`hypersonic_rate_limit_rejections_total{limit_type="Ip",route="/public/telemetry/limit"} 1`,
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Fatalf("metrics missing %s\n%s", expected, metrics)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-16 16:05:44
+ */
+
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"framework/pkg/hypersonic"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 创建令牌测试服务
+func newTokenHypersonic(t *testing.T, config hypersonic.TokenConfig) *hypersonic.Hypersonic {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    config,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/token",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/login",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				req.Token.SetUserId("user-id", time.Hour)
+				return hypersonic.NewData(nil, nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/me",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return hypersonic.NewData(req.Token.MustGetUserId(), nil), nil
+			},
+		}},
+	}})
+
+	return h
+}
+
+// 登录并获取令牌
+func tokenLogin(t *testing.T, h *hypersonic.Hypersonic, cookieName string) string {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/public/token/login", nil))
+
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == cookieName {
+			return cookie.Value
+		}
+	}
+
+	t.Fatalf("cookie %s not set", cookieName)
+	return ""
+}
+
+// 以令牌获取用户 id，令牌无效时为空
+func tokenMe(t *testing.T, h *hypersonic.Hypersonic, token string) any {
+	req := httptest.NewRequest(http.MethodGet, "/public/token/me", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code == http.StatusUnauthorized {
+		return nil
+	}
+
+	var data hypersonic.Data
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	return data.Data
+}
+
+// 登录后获取用户 id，返回令牌
+func tokenRoundTrip(t *testing.T, h *hypersonic.Hypersonic, cookieName string) string {
+	token := tokenLogin(t, h, cookieName)
+
+	if userId := tokenMe(t, h, token); userId != "user-id" {
+		t.Fatalf("user id not match: %v", userId)
+	}
+
+	if userId := tokenMe(t, h, ""); userId != nil {
+		t.Fatalf("no token user id %v", userId)
+	}
+
+	return token
+}
+
+func TestTokenHS256(t *testing.T) {
+	h := newTokenHypersonic(t, hypersonic.TokenConfig{
+		Keys: []hypersonic.TokenKey{{
+			Id:     "2024-07",
+			Secret: []byte("secret"),
+		}},
+		CookieName: "sid",
+	})
+
+	tokenRoundTrip(t, h, "sid")
+}
+
+func TestTokenEdDSA(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	privateBytes, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+	publicBytes, _ := x509.MarshalPKIXPublicKey(publicKey)
+
+	h := newTokenHypersonic(t, hypersonic.TokenConfig{
+		Algorithm: hypersonic.TokenEdDSA,
+		Keys: []hypersonic.TokenKey{{
+			Id:         "2024-07",
+			PrivateKey: pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes}),
+			PublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}),
+		}},
+	})
+
+	tokenRoundTrip(t, h, "token")
+}
+
+func TestTokenRedis(t *testing.T) {
+	h := newTokenHypersonic(t, hypersonic.TokenConfig{
+		Storage: hypersonic.TokenStorageRedis,
+		Redis:   newMiniRedis(t),
+	})
+
+	// 不透明令牌，非 jwt
+	if token := tokenRoundTrip(t, h, "token"); len(token) != 32 || strings.Contains(token, ".") {
+		t.Fatalf("unexpected opaque token %s", token)
+	}
+
+	if userId := tokenMe(t, h, "not-exists"); userId != nil {
+		t.Fatalf("unknown token user id %v", userId)
+	}
+}
+
+func TestTokenRotation(t *testing.T) {
+	oldKey := hypersonic.TokenKey{Id: "2024-07", Secret: []byte("old-secret")}
+	newKey := hypersonic.TokenKey{Id: "2024-08", Secret: []byte("new-secret")}
+
+	token := tokenLogin(t, newTokenHypersonic(t, hypersonic.TokenConfig{Keys: []hypersonic.TokenKey{oldKey}}), "token")
+
+	// 新密钥签发，旧密钥按 kid 验证
+	rotated := newTokenHypersonic(t, hypersonic.TokenConfig{Keys: []hypersonic.TokenKey{newKey, oldKey}})
+	if userId := tokenMe(t, rotated, token); userId != "user-id" {
+		t.Fatalf("old token should verify by kid, got %v", userId)
+	}
+	tokenRoundTrip(t, rotated, "token")
+
+	// 移除旧密钥后失效
+	removed := newTokenHypersonic(t, hypersonic.TokenConfig{Keys: []hypersonic.TokenKey{newKey}})
+	if userId := tokenMe(t, removed, token); userId != nil {
+		t.Fatalf("removed key should not verify, got %v", userId)
+	}
+}
+
+func TestTokenKeysRequired(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// 非开发模式必须配置密钥
+	if _, err := hypersonic.New(hypersonic.Config{I18n: i18n}); err == nil {
+		t.Fatalf("missing token keys should fail outside dev mode")
+	}
+
+	if _, err := hypersonic.New(hypersonic.Config{I18n: i18n, IsDev: true}); err != nil {
+		t.Fatalf(err.Error())
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-01 16:05:48
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"framework/pkg/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/trace",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/context",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				requestTrace, _ := trace.FromContext(req.GetContext())
+				return hypersonic.NewData(requestTrace.RequestId+" "+requestTrace.TraceId, nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodGet,
+			RelativePath: "/error",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				return nil, hypersonic.NewError(hypersonic.CodeConflict)
+			},
+		}},
+	}})
+
+	// 请求
+	call := func(path string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/public/trace"+path, nil)
+		for key, value := range header {
+			req.Header.Set(key, value)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	// 接受上游请求 id 与 traceparent
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	w := call("/context", map[string]string{
+		trace.RequestIdHeader:   "upstream-1",
+		trace.TraceparentHeader: "00-" + traceId + "-00f067aa0ba902b7-01",
+	})
+	if w.Body.String() != `{"data":"upstream-1 `+traceId+`"}` {
+		t.Fatalf("context body %s", w.Body.String())
+	}
+	if w.Header().Get(trace.RequestIdHeader) != "upstream-1" {
+		t.Fatalf("request id header %s", w.Header().Get(trace.RequestIdHeader))
+	}
+	if traceparent := w.Header().Get(trace.TraceparentHeader); !strings.HasPrefix(traceparent, "00-"+traceId+"-") ||
+		strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Fatalf("traceparent header %s", traceparent)
+	}
+
+	// 无效时生成
+	w = call("/context", map[string]string{
+		trace.RequestIdHeader:   "bad id\n",
+		trace.TraceparentHeader: "00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01",
+	})
+	requestId := w.Header().Get(trace.RequestIdHeader)
+	if len(requestId) != 16 || strings.Contains(w.Header().Get(trace.TraceparentHeader), strings.Repeat("0", 32)) {
+		t.Fatalf("generated request id %s, traceparent %s", requestId, w.Header().Get(trace.TraceparentHeader))
+	}
+
+	// 错误响应附加请求 id
+	for _, path := range []string{"/error", "/missing"} {
+		w = call(path, nil)
+
+		var replyErr hypersonic.Error
+		if err = json.Unmarshal(w.Body.Bytes(), &replyErr); err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if replyErr.RequestId == "" || replyErr.RequestId != w.Header().Get(trace.RequestIdHeader) {
+			t.Fatalf("%s error request id %s, header %s", path, replyErr.RequestId, w.Header().Get(trace.RequestIdHeader))
+		}
+	}
+}
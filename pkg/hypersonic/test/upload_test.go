@@ -0,0 +1,155 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-26 11:05:52
+ */
+
+package test
+
+import (
+	"bytes"
+	"framework/pkg/hypersonic"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type uploadParameter struct {
+	Title string                `form:"title" validate:"required"` // 标题
+	File  *multipart.FileHeader `form:"file"`                      // 文件
+}
+
+type uploadResult struct {
+	Title string `json:"title"` // 标题
+	Key   string `json:"key"`   // 存储 key
+}
+
+type formParameter struct {
+	Name string `form:"name" validate:"required"` // 名称
+}
+
+func TestUpload(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	storage, err := hypersonic.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+		Storage:  storage,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/file",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/upload",
+			Handler: hypersonic.Handle(func(req *hypersonic.Request, p *uploadParameter) (*uploadResult, *hypersonic.Error) {
+				key, err := req.SaveFile(p.File)
+				if err != nil {
+					return nil, err
+				}
+
+				return &uploadResult{Title: p.Title, Key: key}, nil
+			}),
+		}, {
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/form",
+			MaxBodySize:  16,
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				var param formParameter
+				if err := req.Bind(&param); err != nil {
+					return nil, err
+				}
+
+				return hypersonic.NewData(param.Name, nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodPut,
+			RelativePath: "/stream",
+			Stream:       true,
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				if req.GetBody() != nil {
+					return nil, hypersonic.NewError(hypersonic.CodeInternalError)
+				}
+
+				key, err := req.Save("stream.BIN", req.GetBodyReader())
+				if err != nil {
+					return nil, err
+				}
+
+				return hypersonic.NewData(key, nil), nil
+			},
+		}},
+	}})
+
+	// 上传
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("title", "avatar")
+	part, _ := writer.CreateFormFile("file", "avatar.PNG")
+	_, _ = part.Write([]byte("png"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/public/file/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"title":"avatar"`) ||
+		!strings.Contains(w.Body.String(), `.png"`) {
+		t.Fatalf("upload status %d, body %s", w.Code, w.Body.String())
+	}
+
+	key := w.Body.String()[strings.Index(w.Body.String(), `"key":"`)+7 : strings.LastIndex(w.Body.String(), `"`)]
+	if reader, err := storage.Open(key); err != nil {
+		t.Fatalf(err.Error())
+	} else if b, _ := io.ReadAll(reader); string(b) != "png" {
+		t.Fatalf("stored %s", b)
+	} else {
+		_ = reader.Close()
+	}
+
+	if _, err = storage.Open("../" + key); err == nil {
+		t.Fatalf("storage key escaped root")
+	}
+
+	// 表单
+	form := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/public/file/form", strings.NewReader(url.Values{"name": {name}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	if w = form("ximei"); w.Code != http.StatusOK || w.Body.String() != `{"data":"ximei"}` {
+		t.Fatalf("form status %d, body %s", w.Code, w.Body.String())
+	}
+
+	if w = form("a very long name"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("too large status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// 流式上传
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/public/file/stream", strings.NewReader("stream")))
+
+	if w.Code != http.StatusOK || !strings.HasSuffix(w.Body.String(), `.bin"}`) {
+		t.Fatalf("stream status %d, body %s", w.Code, w.Body.String())
+	}
+}
@@ -6,15 +6,18 @@
 
 package post_login_name
 
-import "framework/pkg/hypersonic"
+import (
+	"framework/pkg/hypersonic"
+	"net/http"
+)
 
 type data struct {
 	UserId string // 用户id
 }
 
-const (
-	UserLoginRateLimit        hypersonic.Code = "UserLoginRateLimit"
-	UserLoginForbidden                        = "UserLoginForbidden"
-	UserLoginPasswordNotMatch                 = "UserLoginPasswordNotMatch"
-	UserLoginNotFound                         = "UserLoginNotFound"
+var (
+	UserLoginRateLimit        = hypersonic.RegisterCode("UserLoginRateLimit", http.StatusTooManyRequests, "User login rate limit")
+	UserLoginForbidden        = hypersonic.RegisterCode("UserLoginForbidden", http.StatusForbidden, "User login forbidden")
+	UserLoginPasswordNotMatch = hypersonic.RegisterCode("UserLoginPasswordNotMatch", http.StatusBadRequest, "User password not match")
+	UserLoginNotFound         = hypersonic.RegisterCode("UserLoginNotFound", http.StatusNotFound, "User not found")
 )
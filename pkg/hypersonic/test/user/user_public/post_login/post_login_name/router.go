@@ -18,20 +18,12 @@ import (
 
 // Router 路由
 type Router struct {
-	parameterPool  hypersonic.ParameterPool[parameter] // 参数池
-	nameLimit      hypersonic.Limit                    // 用户名限流
-	userRepository user.Repository                     // 用户仓库
+	userRepository user.Repository // 用户仓库
 }
 
 // NewRouter 创建路由
 func NewRouter(mysql *mysql.Mysql, redis *redis.Redis) hypersonic.Router {
 	router := Router{
-		parameterPool: hypersonic.NewParameterPool[parameter](),
-		nameLimit: hypersonic.NewLimit(redis, hypersonic.LimitConfig{
-			LimitType: hypersonic.LimitName,
-			MaxTimes:  1,
-			Interval:  time.Minute,
-		}),
 		userRepository: user.NewRepository(mysql),
 	}
 
@@ -44,31 +36,30 @@ func NewRouter(mysql *mysql.Mysql, redis *redis.Redis) hypersonic.Router {
 				MaxTimes:  1,
 				Interval:  5 * time.Second,
 			}),
+			hypersonic.NewLimit(redis, hypersonic.LimitConfig{
+				LimitType: hypersonic.LimitName,
+				MaxTimes:  1,
+				Interval:  time.Minute,
+				KeyFunc: func(req *hypersonic.Request) string {
+					// 大小写不敏感
+					return strings.ToLower(hypersonic.LimitKeyField("name")(req))
+				},
+				// 仅统计密码错误
+				ErrorCodes: []hypersonic.Code{UserLoginPasswordNotMatch},
+				Code:       UserLoginRateLimit,
+			}),
 		},
-		InvokeFunc: router.invoke,
+		Handler: hypersonic.Handle(router.invoke),
 	}
 }
 
 // 处理
-func (router Router) invoke(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
-	// 参数
-	param := router.parameterPool.Acquire()
-	defer router.parameterPool.Release(param)
-
-	if err := req.Bind(&param); err != nil {
-		return nil, err
-	}
-
+func (router Router) invoke(req *hypersonic.Request, param *parameter) (*data, *hypersonic.Error) {
 	var userId string // userId
 
 	// 大小写不敏感
 	param.Name = strings.ToLower(param.Name)
 
-	// 判断用户名称是否已经登录限制
-	if router.nameLimit.Left(param.Name) == 0 {
-		return nil, hypersonic.NewError(UserLoginRateLimit)
-	}
-
 	// 根据用户名查找用户
 	if userOne := router.userRepository.FindOne("name = ?", param.Name); userOne != nil {
 		// 判断用户名是否已经禁用
@@ -76,18 +67,11 @@ func (router Router) invoke(req *hypersonic.Request) (*hypersonic.Data, *hyperso
 			return nil, hypersonic.NewError(UserLoginForbidden)
 		}
 
-		// 记录登录次数
-		isAddOk := router.nameLimit.Add(param.Name)
-
 		// 验证密码成功
 		if userOne.IsValidPassword(param.Password) {
 			userId = userOne.Id
 		} else {
-			if isAddOk {
-				return nil, hypersonic.NewError(UserLoginPasswordNotMatch)
-			} else {
-				return nil, hypersonic.NewError(UserLoginRateLimit)
-			}
+			return nil, hypersonic.NewError(UserLoginPasswordNotMatch)
 		}
 	} else {
 		// 没有当前用户
@@ -95,14 +79,14 @@ func (router Router) invoke(req *hypersonic.Request) (*hypersonic.Data, *hyperso
 	}
 
 	// 写入令牌
-	req.Token.SetUserId(userId, 30*24*time.Hour)
+	req.Token.Login(userId, param.Refer)
 
 	// 异步记录
 	defer func() {
 
 	}()
 
-	return hypersonic.NewData(data{
+	return &data{
 		UserId: userId,
-	}, nil), nil
+	}, nil
 }
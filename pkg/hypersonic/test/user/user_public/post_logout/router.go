@@ -34,6 +34,7 @@ func NewRouter(mysql *mysql.Mysql, redis *redis.Redis) hypersonic.Router {
 			}),
 		},
 		InvokeFunc: router.invoke,
+		Result:     data{},
 	}
 }
 
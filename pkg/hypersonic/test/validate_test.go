@@ -0,0 +1,149 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-09 10:21:07
+ */
+
+package test
+
+import (
+	"encoding/json"
+	"framework/pkg/hypersonic"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// 验证参数
+type validateParameter struct {
+	UserName string `json:"userName" validate:"required"`   // 用户名
+	Age      int    `json:"age" validate:"required|min:18"` // 年龄
+}
+
+// 创建验证测试服务
+func newValidateHypersonic(t *testing.T, i18n *hypersonic.I18n) *hypersonic.Hypersonic {
+	h, err := hypersonic.New(hypersonic.Config{
+		Listener: hypersonic.NewEchoListener(),
+		I18n:     i18n,
+		Token:    testToken,
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h.RegisterControllers("/public", []hypersonic.Controller{{
+		Path: "/validate",
+		Routers: []hypersonic.Router{{
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/bind",
+			InvokeFunc: func(req *hypersonic.Request) (*hypersonic.Data, *hypersonic.Error) {
+				var p validateParameter
+				if err := req.Bind(&p); err != nil {
+					return nil, err
+				}
+
+				return hypersonic.NewData(p, nil), nil
+			},
+		}, {
+			HttpMethod:   http.MethodPost,
+			RelativePath: "/handle",
+			Handler: hypersonic.Handle(func(req *hypersonic.Request, p *validateParameter) (*validateParameter, *hypersonic.Error) {
+				return p, nil
+			}),
+		}},
+	}})
+
+	return h
+}
+
+func TestValidate(t *testing.T) {
+	i18n, err := hypersonic.NewI18n("i18n")
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	h := newValidateHypersonic(t, i18n)
+
+	for _, c := range []struct {
+		path     string
+		lang     string
+		userName string
+		age      string
+	}{
+		{"/public/validate/bind", "en", "userName must not be empty", "age min value is 18"},
+		{"/public/validate/bind", "zh-CN", "userName 是必填项", "age 的最小值是 18"},
+		{"/public/validate/handle", "en", "userName must not be empty", "age min value is 18"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, c.path, strings.NewReader(`{"age":1}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Accept-Language", c.lang)
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		var reply hypersonic.Error
+		if err = json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+			t.Fatalf(err.Error())
+		}
+
+		if reply.Code != hypersonic.CodeParameterError ||
+			reply.Errors["userName"] != c.userName ||
+			reply.Errors["age"] != c.age {
+			t.Errorf("%s %s unexpected reply %s", c.path, c.lang, w.Body.String())
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/public/validate/bind", strings.NewReader(`{"userName":"sunrui","age":18}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("unexpected reply %s", w.Body.String())
+	}
+}
+
+func TestValidateBaseLang(t *testing.T) {
+	en, _ := os.ReadFile("i18n/en.toml")
+	zh, _ := os.ReadFile("i18n/zh-CN.toml")
+
+	i18n, err := hypersonic.NewI18nFS(fstest.MapFS{
+		"en.toml":    {Data: en},
+		"zh-TW.toml": {Data: zh},
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// zh-TW 按基础语言使用中文验证消息
+	r := httptest.NewRequest(http.MethodPost, "/public/validate/bind", strings.NewReader(`{"age":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Accept-Language", "zh-TW")
+
+	w := httptest.NewRecorder()
+	newValidateHypersonic(t, i18n).ServeHTTP(w, r)
+
+	var reply hypersonic.Error
+	if err = json.Unmarshal(w.Body.Bytes(), &reply); err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	if reply.Errors["userName"] != "userName 是必填项" {
+		t.Errorf("unexpected reply %s", w.Body.String())
+	}
+}
+
+func TestValidateGinBinding(t *testing.T) {
+	// gin ShouldBind* 仍然验证
+	if err := binding.Validator.ValidateStruct(&validateParameter{}); err == nil {
+		t.Fatalf("gin binding should validate")
+	}
+
+	if err := binding.Validator.ValidateStruct(&validateParameter{UserName: "sunrui", Age: 18}); err != nil {
+		t.Fatalf(err.Error())
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-07-26 09:38:20
+ */
+
+package hypersonic
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+)
+
+// 默认最大请求体 32M
+const defaultMaxBodySize = 32 << 20
+
+// 请求体选项
+type bodyOption struct {
+	maxBodySize int64 // 最大请求体字节数
+	stream      bool  // 流式上传
+}
+
+// 记录路由请求体选项
+func (hypersonic *Hypersonic) addBody(fullPath string, router Router) {
+	option := bodyOption{
+		maxBodySize: router.MaxBodySize,
+		stream:      router.Stream,
+	}
+
+	if option.maxBodySize == 0 {
+		option.maxBodySize = hypersonic.maxBodySize
+	}
+
+	methods := []string{router.HttpMethod}
+	if router.HttpMethod == MethodAny {
+		methods = supportedMethods
+	}
+
+	for _, method := range methods {
+		hypersonic.bodies[method+" "+fullPath] = option
+	}
+}
+
+// 获取路由请求体选项，未匹配路由时使用默认值
+func (hypersonic *Hypersonic) getBody(ctx *gin.Context) bodyOption {
+	if option, ok := hypersonic.bodies[ctx.Request.Method+" "+ctx.FullPath()]; ok {
+		return option
+	}
+
+	return bodyOption{
+		maxBodySize: hypersonic.maxBodySize,
+	}
+}
+
+// 是否为 multipart 请求
+func isMultipart(ctx *gin.Context) bool {
+	return ctx.ContentType() == binding.MIMEMultipartPOSTForm
+}
+
+// 是否为表单请求
+func isForm(ctx *gin.Context) bool {
+	return ctx.ContentType() == binding.MIMEPOSTForm || isMultipart(ctx)
+}
+
+// 内容中间件，限制请求体大小，流式上传与 multipart 请求不缓存请求体
+func (hypersonic *Hypersonic) bodyMiddleware(ctx *gin.Context) {
+	option := hypersonic.getBody(ctx)
+
+	if option.maxBodySize > 0 && ctx.Request.Body != nil {
+		if ctx.Request.ContentLength > option.maxBodySize {
+			panic(NewErrorWithArgv(CodeRequestTooLarge, option.maxBodySize))
+		}
+
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, option.maxBodySize)
+	}
+
+	if !option.stream && !isMultipart(ctx) {
+		setBody(ctx, option.maxBodySize)
+	}
+
+	ctx.Next()
+}
+
+// 转换请求体超限错误
+func bodyError(err error, maxBodySize int64) *Error {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return NewErrorWithArgv(CodeRequestTooLarge, maxBodySize)
+	}
+
+	return parameterError(err)
+}
+
+// 获取 multipart 表单
+func (req *Request) getMultipartForm() (*multipart.Form, *Error) {
+	form, err := req.ctx.MultipartForm()
+	if err != nil {
+		return nil, bodyError(err, req.hypersonic.getBody(req.ctx).maxBodySize)
+	}
+
+	return form, nil
+}
+
+// GetFile 获取上传文件
+func (req *Request) GetFile(key string) (*multipart.FileHeader, *Error) {
+	form, err := req.getMultipartForm()
+	if err != nil {
+		return nil, err
+	}
+
+	if files := form.File[key]; len(files) > 0 {
+		return files[0], nil
+	}
+
+	return nil, NewErrorWithMessage(CodeParameterError, "missing file "+key)
+}
+
+// GetMultipartReader 获取 multipart 流，用于 Stream 路由逐个读取文件而不落盘
+func (req *Request) GetMultipartReader() (*multipart.Reader, *Error) {
+	reader, err := req.ctx.Request.MultipartReader()
+	if err != nil {
+		return nil, NewErrorWithMessage(CodeParameterError, err.Error())
+	}
+
+	return reader, nil
+}
+
+// GetBodyReader 获取请求体流，用于 Stream 路由，读取超过最大请求体时返回 http.MaxBytesError
+func (req *Request) GetBodyReader() io.Reader {
+	return req.ctx.Request.Body
+}
+
+// SaveFile 保存上传文件到存储，返回存储 key
+func (req *Request) SaveFile(fileHeader *multipart.FileHeader) (string, *Error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", NewErrorWithMessage(CodeInternalError, err.Error())
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	return req.Save(fileHeader.Filename, file)
+}
+
+// Save 保存流到存储，返回存储 key
+func (req *Request) Save(name string, reader io.Reader) (string, *Error) {
+	if req.hypersonic.storage == nil {
+		panic("hypersonic storage not configured")
+	}
+
+	key, err := req.hypersonic.storage.Save(name, reader)
+	if err != nil {
+		return "", bodyError(err, req.hypersonic.getBody(req.ctx).maxBodySize)
+	}
+
+	return key, nil
+}
+
+// 文件类型
+var (
+	fileHeaderType  = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader{})
+)
+
+// 绑定表单与上传文件，文件字段类型为 *multipart.FileHeader 或 []*multipart.FileHeader
+func (req *Request) bindForm(param any) *Error {
+	if !isMultipart(req.ctx) {
+		if err := req.ctx.Request.ParseForm(); err != nil {
+			return bodyError(err, req.hypersonic.getBody(req.ctx).maxBodySize)
+		}
+
+		if err := binding.MapFormWithTag(param, req.ctx.Request.PostForm, "form"); err != nil {
+			return NewErrorWithMessage(CodeParameterError, err.Error())
+		}
+
+		return nil
+	}
+
+	form, err := req.getMultipartForm()
+	if err != nil {
+		return err
+	}
+
+	if err := binding.MapFormWithTag(param, form.Value, "form"); err != nil {
+		return NewErrorWithMessage(CodeParameterError, err.Error())
+	}
+
+	bindFiles(reflect.ValueOf(param), form.File)
+
+	return nil
+}
+
+// 绑定上传文件，包含匿名字段
+func bindFiles(v reflect.Value, files map[string][]*multipart.FileHeader) {
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, ok := field.Tag.Lookup("form")
+		if !ok || name == "" || name == "-" {
+			if field.Anonymous {
+				bindFiles(v.Field(i), files)
+			}
+			continue
+		}
+
+		if fileHeaders := files[name]; len(fileHeaders) > 0 {
+			switch field.Type {
+			case fileHeaderType:
+				v.Field(i).Set(reflect.ValueOf(fileHeaders[0]))
+			case fileHeadersType:
+				v.Field(i).Set(reflect.ValueOf(fileHeaders))
+			}
+		}
+	}
+}
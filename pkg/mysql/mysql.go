@@ -41,6 +41,11 @@ func New(config Config) (*Mysql, error) {
 			SingularTable: true,   // 使用单数表名
 		},
 	}); err == nil {
+		// 注册链路回调
+		if err = registerTrace(db); err != nil {
+			return nil, err
+		}
+
 		// 配置连接池
 		sqlDb, _ := db.DB()
 		sqlDb.SetMaxOpenConns(config.MaxOpenConns)
@@ -61,3 +66,12 @@ func (mysql Mysql) AutoMigrate(dst ...any) {
 		panic(err.Error())
 	}
 }
+
+// Close 关闭连接池
+func (mysql Mysql) Close() error {
+	if sqlDb, err := mysql.DB.DB(); err != nil {
+		return err
+	} else {
+		return sqlDb.Close()
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-02 11:08:45
+ */
+
+package mysql
+
+import (
+	"errors"
+	"framework/pkg/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const telemetrySpanKey = "telemetry:span" // span 实例键
+
+// 语句执行前创建 span，链路注释随之指向本 span
+func telemetryBefore(t *telemetry.Telemetry, operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx, span := t.StartSpan(db.Statement.Context, "mysql "+operation, oteltrace.SpanKindClient,
+			attribute.String("db.system", "mysql"), attribute.String("db.operation.name", operation))
+
+		db.Statement.Context = ctx
+		db.InstanceSet(telemetrySpanKey, span)
+	}
+}
+
+// 语句执行后结束 span
+func telemetryAfter(db *gorm.DB) {
+	value, ok := db.InstanceGet(telemetrySpanKey)
+	if !ok {
+		return
+	}
+
+	span := value.(oteltrace.Span)
+	span.SetAttributes(
+		attribute.String("db.query.text", db.Statement.SQL.String()),
+		attribute.String("db.collection.name", db.Statement.Table),
+		attribute.Int64("db.response.rows", db.RowsAffected),
+	)
+
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+
+	telemetry.EndSpan(span, err)
+}
+
+// UseTelemetry 启用遥测，记录语句 span 与连接池指标，name 为指标中的数据库名
+func (mysql Mysql) UseTelemetry(t *telemetry.Telemetry, name string) error {
+	sqlDb, err := mysql.DB.DB()
+	if err != nil {
+		return err
+	}
+
+	if err = t.RegisterDBStats(sqlDb, name); err != nil {
+		return err
+	}
+
+	callback := mysql.DB.Callback()
+
+	return errors.Join(
+		callback.Create().Before("trace:create").Register("telemetry:before_create", telemetryBefore(t, "INSERT")),
+		callback.Create().After("gorm:create").Register("telemetry:after_create", telemetryAfter),
+		callback.Query().Before("trace:query").Register("telemetry:before_query", telemetryBefore(t, "SELECT")),
+		callback.Query().After("gorm:query").Register("telemetry:after_query", telemetryAfter),
+		callback.Update().Before("trace:update").Register("telemetry:before_update", telemetryBefore(t, "UPDATE")),
+		callback.Update().After("gorm:update").Register("telemetry:after_update", telemetryAfter),
+		callback.Delete().Before("trace:delete").Register("telemetry:before_delete", telemetryBefore(t, "DELETE")),
+		callback.Delete().After("gorm:delete").Register("telemetry:after_delete", telemetryAfter),
+		callback.Row().Before("gorm:row").Register("telemetry:before_row", telemetryBefore(t, "ROW")),
+		callback.Row().After("gorm:row").Register("telemetry:after_row", telemetryAfter),
+		callback.Raw().Before("gorm:raw").Register("telemetry:before_raw", telemetryBefore(t, "RAW")),
+		callback.Raw().After("gorm:raw").Register("telemetry:after_raw", telemetryAfter),
+	)
+}
@@ -0,0 +1,68 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-01 14:36:20
+ */
+
+package mysql
+
+import (
+	"context"
+	"framework/pkg/trace"
+	"net/url"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 链路注释，格式兼容 sqlcommenter
+type traceComment struct {
+	trace trace.Trace // 链路
+}
+
+// Build 构建
+func (traceComment traceComment) Build(builder clause.Builder) {
+	builder.WriteString("/* requestId='" + url.QueryEscape(traceComment.trace.RequestId) +
+		"',traceparent='" + traceComment.trace.Traceparent() + "' */")
+}
+
+// 语句首个子句附加链路注释，便于与慢查询日志关联
+func traceCallback(clauseName string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		t, ok := trace.FromContext(db.Statement.Context)
+		if !ok {
+			return
+		}
+
+		// 已有前置表达式时不覆盖
+		if c, ok := db.Statement.Clauses[clauseName]; !ok || c.BeforeExpression == nil {
+			c.Name = clauseName
+			c.BeforeExpression = traceComment{trace: t}
+			db.Statement.Clauses[clauseName] = c
+		}
+	}
+}
+
+// 注册链路回调
+func registerTrace(db *gorm.DB) error {
+	callback := db.Callback()
+
+	if err := callback.Query().Before("gorm:query").Register("trace:query", traceCallback("SELECT")); err != nil {
+		return err
+	}
+	if err := callback.Create().Before("gorm:create").Register("trace:create", traceCallback("INSERT")); err != nil {
+		return err
+	}
+	if err := callback.Update().Before("gorm:update").Register("trace:update", traceCallback("UPDATE")); err != nil {
+		return err
+	}
+
+	return callback.Delete().Before("gorm:delete").Register("trace:delete", traceCallback("DELETE"))
+}
+
+// WithContext 绑定上下文，上下文含链路时语句附加请求 id 与 traceparent 注释，如 mysql.WithContext(req.GetContext())
+func (mysql Mysql) WithContext(ctx context.Context) *Mysql {
+	return &Mysql{
+		DB: mysql.DB.WithContext(ctx),
+	}
+}
@@ -7,6 +7,7 @@
 package mysql
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"framework/pkg/hypersonic"
@@ -26,6 +27,13 @@ func NewRepository[T any](mysql *Mysql) Repository[T] {
 	}
 }
 
+// WithContext 绑定上下文，语句附加请求链路，如 repository.WithContext(req.GetContext()).FindById(id)
+func (repository Repository[T]) WithContext(ctx context.Context) Repository[T] {
+	return Repository[T]{
+		Mysql: repository.Mysql.WithContext(ctx),
+	}
+}
+
 // Count 总数
 func (repository Repository[T]) Count() (count int64) {
 	var dst T
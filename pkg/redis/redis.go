@@ -128,6 +128,24 @@ func (redis *Redis) GetHash(hash string, key string) (value []byte, ok bool) {
 	}
 }
 
+// GetHashAll 获取 hash 所有值
+func (redis *Redis) GetHashAll(hash string) map[string]string {
+	if cmd := redis.client.HGetAll(redis.context, hash); cmd.Err() != nil {
+		panic(cmd.Err())
+	} else {
+		return cmd.Val()
+	}
+}
+
+// DelHash 删除 hash
+func (redis *Redis) DelHash(hash string, key string) bool {
+	if cmd := redis.client.HDel(redis.context, hash, key); cmd.Err() != nil {
+		panic(cmd.Err())
+	} else {
+		return cmd.Val() == 1
+	}
+}
+
 // Exists 是否存在
 func (redis *Redis) Exists(key string) bool {
 	if cmd := redis.client.Exists(redis.context, key); cmd.Err() != nil {
@@ -145,3 +163,58 @@ func (redis *Redis) Del(key string) bool {
 		return cmd.Val() == 1
 	}
 }
+
+// Script lua 脚本
+type Script struct {
+	script *redis.Script // 脚本
+}
+
+// NewScript 创建 lua 脚本
+func NewScript(src string) Script {
+	return Script{
+		script: redis.NewScript(src),
+	}
+}
+
+// RunScript 原子执行 lua 脚本，优先使用 EVALSHA，返回整数数组
+func (redis *Redis) RunScript(script Script, keys []string, args ...any) []int64 {
+	if values, err := script.script.Run(redis.context, redis.client, keys, args...).Int64Slice(); err != nil {
+		panic(err)
+	} else {
+		return values
+	}
+}
+
+// Publish 发布消息
+func (redis *Redis) Publish(channel string, message []byte) {
+	if cmd := redis.client.Publish(redis.context, channel, message); cmd.Err() != nil {
+		panic(cmd.Err())
+	}
+}
+
+// Subscribe 订阅频道，返回消息通道与取消订阅函数，取消后通道关闭
+func (redis *Redis) Subscribe(channel string) (<-chan []byte, func() error, error) {
+	pubSub := redis.client.Subscribe(redis.context, channel)
+
+	// 等待订阅确认，避免之后发布的消息丢失
+	if _, err := pubSub.Receive(redis.context); err != nil {
+		_ = pubSub.Close()
+		return nil, nil, err
+	}
+
+	messages := make(chan []byte)
+	go func() {
+		defer close(messages)
+
+		for message := range pubSub.Channel() {
+			messages <- []byte(message.Payload)
+		}
+	}()
+
+	return messages, pubSub.Close, nil
+}
+
+// Close 关闭连接
+func (redis *Redis) Close() error {
+	return redis.client.Close()
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-02 13:46:02
+ */
+
+package redis
+
+import (
+	"context"
+	"errors"
+	"framework/pkg/telemetry"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	oteltrace "go.opentelemetry.io/otel/trace"
+)
+
+// 遥测钩子
+type telemetryHook struct {
+	telemetry *telemetry.Telemetry // 遥测
+}
+
+// DialHook 连接
+func (hook telemetryHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook 命令，记录 span，不记录参数以免泄露数据
+func (hook telemetryHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := hook.telemetry.StartSpan(ctx, "redis "+cmd.Name(), oteltrace.SpanKindClient,
+			attribute.String("db.system", "redis"), attribute.String("db.operation.name", cmd.Name()))
+
+		err := next(ctx, cmd)
+		telemetry.EndSpan(span, ignoreNil(err))
+
+		return err
+	}
+}
+
+// ProcessPipelineHook 管道
+func (hook telemetryHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, 0, len(cmds))
+		for _, cmd := range cmds {
+			names = append(names, cmd.Name())
+		}
+
+		ctx, span := hook.telemetry.StartSpan(ctx, "redis pipeline", oteltrace.SpanKindClient,
+			attribute.String("db.system", "redis"), attribute.String("db.operation.name", strings.Join(names, " ")))
+
+		err := next(ctx, cmds)
+		telemetry.EndSpan(span, ignoreNil(err))
+
+		return err
+	}
+}
+
+// 键不存在不视为错误
+func ignoreNil(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+
+	return err
+}
+
+// UseTelemetry 启用遥测，记录命令 span
+func (redis *Redis) UseTelemetry(t *telemetry.Telemetry) {
+	redis.client.AddHook(telemetryHook{
+		telemetry: t,
+	})
+}
+
+// WithContext 绑定上下文，命令 span 以上下文中的请求链路为父，如 redis.WithContext(req.GetContext())
+func (redis *Redis) WithContext(ctx context.Context) *Redis {
+	return &Redis{
+		context: ctx,
+		client:  redis.client,
+	}
+}
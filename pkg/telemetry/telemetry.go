@@ -0,0 +1,179 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-02 09:31:17
+ */
+
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"framework/pkg/trace"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Config 遥测配置，链路与指标均可选
+type Config struct {
+	TracerProvider oteltrace.TracerProvider // 链路，如 sdktrace.NewTracerProvider，为空时不记录 span
+	Registry       *prometheus.Registry     // 指标，为空时不记录指标
+}
+
+// Telemetry 遥测，为 nil 时所有方法为空操作
+type Telemetry struct {
+	tracer   oteltrace.Tracer     // 链路
+	registry *prometheus.Registry // 指标
+
+	requestDuration     *prometheus.HistogramVec // 请求耗时
+	rateLimitRejections *prometheus.CounterVec   // 限流拒绝
+	consumerLag         *prometheus.HistogramVec // 消费延迟
+}
+
+// New 创建
+func New(config Config) *Telemetry {
+	telemetry := &Telemetry{
+		registry: config.Registry,
+	}
+
+	if config.TracerProvider != nil {
+		telemetry.tracer = config.TracerProvider.Tracer("framework")
+	}
+
+	if config.Registry != nil {
+		telemetry.requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "hypersonic_request_duration_seconds",
+			Help:    "Request latency by route and code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "code"})
+
+		telemetry.rateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "hypersonic_rate_limit_rejections_total",
+			Help: "Requests rejected by rate limits.",
+		}, []string{"route", "limit_type"})
+
+		telemetry.consumerLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "amqp_consumer_lag_seconds",
+			Help:    "Delay between message publish and consume.",
+			Buckets: []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 300},
+		}, []string{"topic"})
+
+		config.Registry.MustRegister(telemetry.requestDuration, telemetry.rateLimitRejections, telemetry.consumerLag)
+	}
+
+	return telemetry
+}
+
+// StartSpan 创建 span，上下文无 span 但含请求链路时以上游为父，并将链路更新为本 span 以便继续传递
+func (telemetry *Telemetry) StartSpan(ctx context.Context, name string, kind oteltrace.SpanKind,
+	attrs ...attribute.KeyValue) (context.Context, oteltrace.Span) {
+	if telemetry == nil || telemetry.tracer == nil {
+		return ctx, noop.Span{}
+	}
+
+	requestTrace, hasTrace := trace.FromContext(ctx)
+	if hasTrace && !oteltrace.SpanContextFromContext(ctx).IsValid() {
+		if parent := remoteSpanContext(requestTrace); parent.IsValid() {
+			ctx = oteltrace.ContextWithRemoteSpanContext(ctx, parent)
+		}
+	}
+
+	ctx, span := telemetry.tracer.Start(ctx, name, oteltrace.WithSpanKind(kind), oteltrace.WithAttributes(attrs...))
+
+	if spanContext := span.SpanContext(); hasTrace && spanContext.IsValid() {
+		requestTrace.TraceId = spanContext.TraceID().String()
+		requestTrace.SpanId = spanContext.SpanID().String()
+		ctx = trace.NewContext(ctx, requestTrace)
+	}
+
+	return ctx, span
+}
+
+// 上游 span
+func remoteSpanContext(requestTrace trace.Trace) oteltrace.SpanContext {
+	traceId, err := oteltrace.TraceIDFromHex(requestTrace.TraceId)
+	if err != nil {
+		return oteltrace.SpanContext{}
+	}
+
+	spanId, err := oteltrace.SpanIDFromHex(requestTrace.ParentId)
+	if err != nil {
+		return oteltrace.SpanContext{}
+	}
+
+	var flags oteltrace.TraceFlags
+	if b, err := hex.DecodeString(requestTrace.Flags); err == nil && len(b) == 1 {
+		flags = oteltrace.TraceFlags(b[0])
+	}
+
+	return oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
+
+// EndSpan 结束 span，失败时记录错误
+func EndSpan(span oteltrace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// ObserveRequest 记录请求耗时
+func (telemetry *Telemetry) ObserveRequest(method string, route string, code string, elapsed time.Duration) {
+	if telemetry == nil || telemetry.registry == nil {
+		return
+	}
+
+	telemetry.requestDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// AddRateLimitRejection 记录限流拒绝
+func (telemetry *Telemetry) AddRateLimitRejection(route string, limitType string) {
+	if telemetry == nil || telemetry.registry == nil {
+		return
+	}
+
+	telemetry.rateLimitRejections.WithLabelValues(route, limitType).Inc()
+}
+
+// ObserveConsumerLag 记录消费延迟
+func (telemetry *Telemetry) ObserveConsumerLag(topic string, lag time.Duration) {
+	if telemetry == nil || telemetry.registry == nil {
+		return
+	}
+
+	telemetry.consumerLag.WithLabelValues(topic).Observe(max(lag, 0).Seconds())
+}
+
+// RegisterDBStats 注册连接池指标
+func (telemetry *Telemetry) RegisterDBStats(db *sql.DB, name string) error {
+	if telemetry == nil || telemetry.registry == nil {
+		return nil
+	}
+
+	return telemetry.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler 指标地址处理器，未配置指标时为 nil
+func (telemetry *Telemetry) Handler() http.Handler {
+	if telemetry == nil || telemetry.registry == nil {
+		return nil
+	}
+
+	return promhttp.HandlerFor(telemetry.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,156 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-01 10:12:36
+ */
+
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"framework/pkg/utils"
+	"log/slog"
+	"strings"
+)
+
+const (
+	RequestIdHeader   = "X-Request-ID" // 请求 id 首部
+	TraceparentHeader = "traceparent"  // W3C 链路首部
+)
+
+// Trace 链路，请求 id 与 W3C traceparent
+type Trace struct {
+	RequestId string // 请求 id
+	TraceId   string // 链路 id，32 位十六进制
+	ParentId  string // 上游 span id，无上游时为空
+	SpanId    string // 本服务 span id，16 位十六进制
+	Flags     string // 标志，01 为采样
+}
+
+// 随机十六进制
+func randomHex(size int) string {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		panic(err.Error())
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// 是否有效的十六进制
+func isHex(value string, size int) bool {
+	if len(value) != size {
+		return false
+	}
+
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 是否有效的请求 id，最长 128 位，仅允许字母、数字与 -_.:
+func isRequestId(value string) bool {
+	if value == "" || len(value) > 128 {
+		return false
+	}
+
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// New 创建链路
+func New() Trace {
+	return Parse("", "")
+}
+
+// Parse 解析上游请求 id 与 traceparent，无效时重新生成，并创建本服务 span
+func Parse(requestId string, traceparent string) Trace {
+	trace := Trace{
+		RequestId: requestId,
+		SpanId:    randomHex(8),
+		Flags:     "01",
+	}
+
+	// version-traceId-parentId-flags，id 不能全为 0
+	if parts := strings.Split(strings.TrimSpace(traceparent), "-"); len(parts) == 4 &&
+		isHex(parts[0], 2) && parts[0] != "ff" && isHex(parts[3], 2) &&
+		isHex(parts[1], 32) && strings.Trim(parts[1], "0") != "" &&
+		isHex(parts[2], 16) && strings.Trim(parts[2], "0") != "" {
+		trace.TraceId = parts[1]
+		trace.ParentId = parts[2]
+		trace.Flags = parts[3]
+	} else {
+		trace.TraceId = randomHex(16)
+	}
+
+	if !isRequestId(trace.RequestId) {
+		trace.RequestId = utils.NanoId(16)
+	}
+
+	return trace
+}
+
+// Traceparent 本服务 traceparent，用于传递到下游
+func (trace Trace) Traceparent() string {
+	return "00-" + trace.TraceId + "-" + trace.SpanId + "-" + trace.Flags
+}
+
+// 上下文键
+type contextKey struct{}
+
+// NewContext 附加链路到上下文
+func NewContext(ctx context.Context, trace Trace) context.Context {
+	return context.WithValue(ctx, contextKey{}, trace)
+}
+
+// FromContext 从上下文获取链路
+func FromContext(ctx context.Context) (Trace, bool) {
+	if ctx == nil {
+		return Trace{}, false
+	}
+
+	trace, ok := ctx.Value(contextKey{}).(Trace)
+	return trace, ok
+}
+
+// Handler slog 处理器，以 *Context 方法记录日志时附加 requestId 与 traceId
+type Handler struct {
+	slog.Handler
+}
+
+// NewHandler 创建 slog 处理器，如 slog.SetDefault(slog.New(trace.NewHandler(handler)))
+func NewHandler(handler slog.Handler) *Handler {
+	return &Handler{
+		Handler: handler,
+	}
+}
+
+// Handle 处理
+func (handler *Handler) Handle(ctx context.Context, record slog.Record) error {
+	if trace, ok := FromContext(ctx); ok {
+		record.AddAttrs(slog.String("requestId", trace.RequestId), slog.String("traceId", trace.TraceId))
+	}
+
+	return handler.Handler.Handle(ctx, record)
+}
+
+// WithAttrs 附加属性
+func (handler *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return NewHandler(handler.Handler.WithAttrs(attrs))
+}
+
+// WithGroup 附加分组
+func (handler *Handler) WithGroup(name string) slog.Handler {
+	return NewHandler(handler.Handler.WithGroup(name))
+}
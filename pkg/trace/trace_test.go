@@ -0,0 +1,37 @@
+/*
+ * Copyright © 2024 honeysense.com All rights reserved.
+ * Author: sunrui
+ * Date: 2024-08-01 17:20:31
+ */
+
+package trace
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	buffer := bytes.Buffer{}
+	logger := slog.New(NewHandler(slog.NewJSONHandler(&buffer, nil))).With("module", "test")
+
+	trace := Parse("req-1", "")
+	logger.InfoContext(NewContext(context.Background(), trace), "hello")
+	logger.Info("plain")
+
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("lines %d", len(lines))
+	}
+
+	if !strings.Contains(lines[0], `"requestId":"req-1"`) || !strings.Contains(lines[0], `"traceId":"`+trace.TraceId+`"`) {
+		t.Fatalf("context record %s", lines[0])
+	}
+
+	if strings.Contains(lines[1], "requestId") {
+		t.Fatalf("plain record %s", lines[1])
+	}
+}